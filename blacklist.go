@@ -1,7 +1,10 @@
 package lizt
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -11,13 +14,16 @@ import (
 type BlacklistingIterator struct {
 	Blacklister
 	PointerIterator
-	blacklist *BlacklistManager
+	blacklist  BlacklistBackend
+	normalizer Normalizer
 }
 
-// BlacklistingIteratorConfig is the config for a blacklisting iterator.
+// BlacklistingIteratorConfig is the config for a blacklisting iterator. The Normalizer is optional and is applied to
+// a line before it is checked against the blacklist. The line itself is emitted as is.
 type BlacklistingIteratorConfig struct {
 	PointerIter PointerIterator
-	Blacklisted *BlacklistManager
+	Blacklisted BlacklistBackend
+	Normalizer  Normalizer
 }
 
 // NewBlacklistingIterator returns a new persistent iterator. It will set the pointer to the last known pointer.
@@ -25,6 +31,7 @@ func NewBlacklistingIterator(cfg BlacklistingIteratorConfig) (*BlacklistingItera
 	blkIter := &BlacklistingIterator{
 		PointerIterator: cfg.PointerIter,
 		blacklist:       cfg.Blacklisted,
+		normalizer:      cfg.Normalizer,
 	}
 
 	return blkIter, nil
@@ -32,24 +39,31 @@ func NewBlacklistingIterator(cfg BlacklistingIteratorConfig) (*BlacklistingItera
 
 // Next returns the next line from the iterator.
 func (bi *BlacklistingIterator) Next(count int) ([]string, error) {
-	var clean []string
-	for len(clean) < count {
-		next, err := bi.PointerIterator.Next(count - len(clean))
-		if err != nil {
-			return nil, fmt.Errorf("next: name: %s -> %w", bi.Name(), err)
-		}
+	return bi.NextContext(context.Background(), count)
+}
 
-		for _, n := range next {
-			if !bi.IsBlacklisted(n) {
-				clean = append(clean, n)
-			}
-		}
-	}
-	return clean, nil
+// NextContext returns the next non-blacklisted lines from the iterator. It stops early if ctx is done, which keeps
+// a heavily blacklisted round-robin list from spinning forever. There's no cycle guard, since a watched blacklist
+// can drop entries while it waits.
+func (bi *BlacklistingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	return nextKept(ctx, bi.PointerIterator, "next", count, false, func(line string) bool { return !bi.IsBlacklisted(line) })
+}
+
+// advance moves the wrapped iterator forward to line p.
+func (bi *BlacklistingIterator) advance(p uint64) {
+	advanceTo(bi.PointerIterator, p)
+}
+
+// Unwrap returns the wrapped iterator.
+func (bi *BlacklistingIterator) Unwrap() PointerIterator {
+	return bi.PointerIterator
 }
 
 // IsBlacklisted returns true if the given line is blacklisted.
 func (bi *BlacklistingIterator) IsBlacklisted(line string) bool {
+	if bi.normalizer != nil {
+		line = bi.normalizer(line)
+	}
 	return bi.blacklist.Has(line)
 }
 
@@ -81,7 +95,7 @@ func (bi *BlacklistingIterator) MustNextOne() string {
 }
 
 // ScrubFileWithBlacklist iterates over every line in a file and saves to a new file with the blacklisted lines removed.
-func ScrubFileWithBlacklist(blkMap BlacklistMap, sourcePath, destPath string) (n int, err error) {
+func ScrubFileWithBlacklist(blacklist BlacklistBackend, sourcePath, destPath string) (n int, err error) {
 	// Read from source file
 	source, err := ReadFromFile(sourcePath)
 	if err != nil {
@@ -93,10 +107,11 @@ func ScrubFileWithBlacklist(blkMap BlacklistMap, sourcePath, destPath string) (n
 	if err != nil {
 		return 0, fmt.Errorf("create dest: %w", err)
 	}
+	defer dest.Close()
 
 	sb := strings.Builder{}
 	for _, line := range source {
-		if _, ok := blkMap[line]; !ok {
+		if !blacklist.Has(line) {
 			sb.WriteString(line + "\n")
 		} else {
 			n++
@@ -113,9 +128,22 @@ func ScrubFileWithBlacklist(blkMap BlacklistMap, sourcePath, destPath string) (n
 
 type BlacklistMap map[string]struct{}
 
+// Has returns true if the given string is in the map
+func (m BlacklistMap) Has(who string) bool {
+	_, ok := m[who]
+	return ok
+}
+
+// BlacklistTombstone marks a removed entry in the file of a file-backed BlacklistManager.
+const BlacklistTombstone = "\x7f"
+
 type BlacklistManager struct {
-	mu    sync.Mutex
-	items BlacklistMap
+	mu         sync.Mutex
+	items      BlacklistMap
+	normalizer Normalizer
+	file       *os.File
+	path       string
+	dead       int
 }
 
 func NewBlacklistManager(items BlacklistMap) *BlacklistManager {
@@ -124,12 +152,194 @@ func NewBlacklistManager(items BlacklistMap) *BlacklistManager {
 	}
 }
 
+// NewFileBlacklistManager returns a blacklist manager backed by a file, which is created if it doesn't exist. Add
+// appends the entry to the file and Remove appends a tombstone (the entry prefixed with BlacklistTombstone), each
+// synced before returning, so the blacklist survives a crash. Compact rewrites the file without the tombstones.
+// Call Close to release the file.
+func NewFileBlacklistManager(path string) (*BlacklistManager, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile(): %s -> %w", path, err)
+	}
+
+	c, err := detectCompression(file)
+	if err == nil && c != CompressionNone {
+		err = fmt.Errorf("blacklist: %s: %s -> %w", path, c, ErrCompressed)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	l := &BlacklistManager{items: make(BlacklistMap), file: file, path: path}
+	if err = l.load(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// load replays the file. A missing newline at the end, e.g. from a crash mid-append, is fixed so the next entry
+// starts on its own line.
+func (l *BlacklistManager) load() error {
+	if err := l.replay(); err != nil {
+		return err
+	}
+
+	info, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat(): %s -> %w", l.path, err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	last := make([]byte, 1)
+	if _, err = l.file.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("file.ReadAt(): %s -> %w", l.path, err)
+	}
+	if last[0] != '\n' {
+		return l.write("")
+	}
+	return nil
+}
+
+// replay reads the file from the start. Entries and tombstones are normalized alike, so a tombstone written for a
+// normalized entry removes the raw entry it was normalized from.
+func (l *BlacklistManager) replay() error {
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("file.Seek(): %s -> %w", l.path, err)
+	}
+
+	items := make(BlacklistMap)
+	scanner := bufio.NewScanner(l.file)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, BlacklistTombstone) {
+			delete(items, l.normalize(strings.TrimPrefix(line, BlacklistTombstone)))
+			continue
+		}
+		if line != "" {
+			items[l.normalize(line)] = struct{}{}
+		}
+	}
+	if scanner.Err() != nil {
+		return fmt.Errorf("blacklist: %s -> %w", l.path, scanner.Err())
+	}
+	l.items = items
+	l.dead = lines - len(items)
+	return nil
+}
+
+// write appends a line to the file and syncs it. It is a no-op for managers that aren't file-backed.
+func (l *BlacklistManager) write(line string) error {
+	if l.file == nil {
+		return nil
+	}
+	if _, err := l.file.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("blacklist: %s -> %w", l.path, err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("blacklist: %s -> %w", l.path, err)
+	}
+	return nil
+}
+
+// Compact rewrites the file of a file-backed manager with only the current entries.
+func (l *BlacklistManager) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	sb := strings.Builder{}
+	for item := range l.items {
+		sb.WriteString(item + "\n")
+	}
+	if err := WriteFileAtomic(l.path, []byte(sb.String())); err != nil {
+		return err
+	}
+
+	// the old handle points at the replaced file.
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(): %s -> %w", l.path, err)
+	}
+	_ = l.file.Close()
+	l.file = file
+	l.dead = 0
+	return nil
+}
+
+// Dead returns the number of lines in the file that Compact would drop.
+func (l *BlacklistManager) Dead() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.dead
+}
+
+// Close closes the file of a file-backed manager.
+func (l *BlacklistManager) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Normalize normalizes the items of the list, and every string passed to Has, Add, Remove and Swap from now on.
+func (l *BlacklistManager) Normalize(n Normalizer) *BlacklistManager {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.normalizer = n
+	// a file-backed manager replays its file, so tombstones apply to the normalized entries. If it can't be read,
+	// the entries in memory are normalized instead.
+	if l.file != nil && l.replay() == nil {
+		return l
+	}
+	before := len(l.items)
+	l.items = l.normalizeMap(l.items)
+	if l.file != nil {
+		l.dead += before - len(l.items)
+	}
+	return l
+}
+
+func (l *BlacklistManager) normalize(who string) string {
+	if l.normalizer == nil {
+		return who
+	}
+	return l.normalizer(who)
+}
+
+func (l *BlacklistManager) normalizeMap(items BlacklistMap) BlacklistMap {
+	if l.normalizer == nil {
+		return items
+	}
+
+	normalized := make(BlacklistMap, len(items))
+	for item := range items {
+		normalized[l.normalizer(item)] = struct{}{}
+	}
+	return normalized
+}
+
 // Has returns true if the given string is in the list
 func (l *BlacklistManager) Has(who string) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	_, ok := l.items[who]
+	_, ok := l.items[l.normalize(who)]
 	return ok
 }
 
@@ -141,19 +351,55 @@ func (l *BlacklistManager) Map() BlacklistMap {
 	return l.items
 }
 
-// Add adds a string to the list and appends to a file at the given path
+// Add adds a string to the list. A file-backed manager appends it to its file first, so it rejects entries that
+// the file couldn't hold as they are (see fileEntryErr). An in-memory manager takes any string.
 func (l *BlacklistManager) Add(who string) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	who = l.normalize(who)
 	if _, ok := l.items[who]; ok {
 		return fmt.Errorf("already in list")
 	}
+	if l.file != nil {
+		if err := fileEntryErr(who); err != nil {
+			return err
+		}
+	}
 
+	if err := l.write(who); err != nil {
+		return err
+	}
 	l.items[who] = struct{}{}
 	return nil
 }
 
+// fileEntryErr returns an error for entries that wouldn't read back the same from a blacklist file: empty ones,
+// ones with a newline or leading or trailing whitespace, and ones that start with BlacklistTombstone.
+func fileEntryErr(who string) error {
+	switch {
+	case who == "":
+		return fmt.Errorf("empty entry")
+	case strings.ContainsAny(who, "\r\n"):
+		return fmt.Errorf("contains a newline")
+	case strings.TrimSpace(who) != who:
+		return fmt.Errorf("has leading or trailing whitespace")
+	case strings.HasPrefix(who, BlacklistTombstone):
+		return fmt.Errorf("starts with the tombstone marker")
+	}
+	return nil
+}
+
+// Swap replaces the items of the list and returns the old ones. A file-backed manager's file is left untouched.
+func (l *BlacklistManager) Swap(items BlacklistMap) BlacklistMap {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	old := l.items
+	l.items = l.normalizeMap(items)
+	return old
+}
+
 // Len returns the length of the list
 func (l *BlacklistManager) Len() int {
 	l.mu.Lock()
@@ -167,10 +413,17 @@ func (l *BlacklistManager) Remove(who string) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	who = l.normalize(who)
 	if _, ok := l.items[who]; !ok {
 		return fmt.Errorf("not in list")
 	}
 
+	if l.file != nil {
+		if err := l.write(BlacklistTombstone + who); err != nil {
+			return err
+		}
+		l.dead += 2
+	}
 	delete(l.items, who)
 	return nil
 }
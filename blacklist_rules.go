@@ -0,0 +1,246 @@
+package lizt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+// RuleKind is the kind of pattern a blacklist rule matches with.
+type RuleKind int
+
+const (
+	// RuleExact matches the whole line.
+	RuleExact RuleKind = iota
+	// RulePrefix matches lines starting with the pattern.
+	RulePrefix
+	// RuleSuffix matches lines ending with the pattern.
+	RuleSuffix
+	// RuleGlob matches the whole line against a glob, where * matches any run of characters and ? matches one.
+	RuleGlob
+	// RuleRegex matches lines containing a match of the regular expression.
+	RuleRegex
+	// RuleCIDR matches lines whose IP, e.g. of "ip", "ip:port" or "ip:port:user:pass", is in the network.
+	RuleCIDR
+)
+
+// ruleKinds maps the kinds to their prefix in a rules file.
+var ruleKinds = map[string]RuleKind{
+	"exact":  RuleExact,
+	"prefix": RulePrefix,
+	"suffix": RuleSuffix,
+	"glob":   RuleGlob,
+	"regex":  RuleRegex,
+	"cidr":   RuleCIDR,
+}
+
+// String returns the name of the kind, as used in a rules file.
+func (k RuleKind) String() string {
+	for name, kind := range ruleKinds {
+		if kind == k {
+			return name
+		}
+	}
+	return fmt.Sprintf("RuleKind(%d)", int(k))
+}
+
+// Rule is a single blacklist rule.
+type Rule struct {
+	Kind    RuleKind
+	Pattern string
+}
+
+// ParseRule parses a line of a rules file, which is "<kind>:<pattern>", e.g. "suffix:@example.com" or
+// "cidr:10.0.0.0/8". A line without a known kind is an exact rule.
+func ParseRule(line string) (Rule, error) {
+	if kind, pattern, ok := strings.Cut(line, ":"); ok {
+		if k, ok := ruleKinds[kind]; ok {
+			if pattern == "" {
+				return Rule{}, fmt.Errorf("rule: %q -> %w", line, ErrInvalidRule)
+			}
+			return Rule{Kind: k, Pattern: pattern}, nil
+		}
+	}
+	return Rule{Kind: RuleExact, Pattern: line}, nil
+}
+
+// RuleBlacklist is a blacklist backend that matches lines against rules instead of a fixed set of lines.
+// Exact rules are looked up in a map. Every other rule is tried in turn, so keep the number of patterns moderate.
+type RuleBlacklist struct {
+	exact    BlacklistMap
+	prefixes []string
+	suffixes []string
+	regexes  []*regexp.Regexp
+	nets     []*net.IPNet
+	rules    []Rule
+	mu       sync.RWMutex
+}
+
+// NewRuleBlacklist returns a new rule blacklist.
+func NewRuleBlacklist(rules ...Rule) (*RuleBlacklist, error) {
+	rb := &RuleBlacklist{exact: make(BlacklistMap)}
+	for _, rule := range rules {
+		if err := rb.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rb, nil
+}
+
+// LoadRuleBlacklist reads rules from a file, one per line. Blank lines and lines starting with # are skipped.
+func LoadRuleBlacklist(filename string) (*RuleBlacklist, error) {
+	file, err := OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rb := &RuleBlacklist{exact: make(BlacklistMap)}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := ParseRule(line)
+		if err == nil {
+			err = rb.Add(rule)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rules: %s: line %d -> %w", filename, n, err)
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("rules: %s -> %w", filename, scanner.Err())
+	}
+	return rb, nil
+}
+
+// Add adds a rule.
+func (rb *RuleBlacklist) Add(rule Rule) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	switch rule.Kind {
+	case RuleExact:
+		rb.exact[rule.Pattern] = struct{}{}
+	case RulePrefix:
+		rb.prefixes = append(rb.prefixes, rule.Pattern)
+	case RuleSuffix:
+		rb.suffixes = append(rb.suffixes, rule.Pattern)
+	case RuleGlob:
+		re, err := regexp.Compile(globToRegex(rule.Pattern))
+		if err != nil {
+			return fmt.Errorf("rule: glob: %q: %v -> %w", rule.Pattern, err, ErrInvalidRule)
+		}
+		rb.regexes = append(rb.regexes, re)
+	case RuleRegex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule: regex: %q: %v -> %w", rule.Pattern, err, ErrInvalidRule)
+		}
+		rb.regexes = append(rb.regexes, re)
+	case RuleCIDR:
+		_, network, err := net.ParseCIDR(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule: cidr: %q: %v -> %w", rule.Pattern, err, ErrInvalidRule)
+		}
+		rb.nets = append(rb.nets, network)
+	default:
+		return fmt.Errorf("rule: %s -> %w", rule.Kind, ErrInvalidRule)
+	}
+
+	rb.rules = append(rb.rules, rule)
+	return nil
+}
+
+// Rules returns the rules in the order they were added.
+func (rb *RuleBlacklist) Rules() []Rule {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	return append([]Rule{}, rb.rules...)
+}
+
+// Has returns true if any rule matches the line.
+func (rb *RuleBlacklist) Has(line string) bool {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	if _, ok := rb.exact[line]; ok {
+		return true
+	}
+	for _, prefix := range rb.prefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range rb.suffixes {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+	for _, re := range rb.regexes {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	if len(rb.nets) > 0 {
+		if ip := lineIP(line); ip != nil {
+			for _, network := range rb.nets {
+				if network.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// globToRegex translates a glob into an anchored regular expression.
+func globToRegex(glob string) string {
+	quoted := regexp.QuoteMeta(glob)
+	quoted = strings.ReplaceAll(quoted, `\*`, `.*`)
+	quoted = strings.ReplaceAll(quoted, `\?`, `.`)
+	return "^" + quoted + "$"
+}
+
+// lineIP returns the IP a line starts with, e.g. of "ip", "ip:port", "[ipv6]:port" or "ip:port:user:pass".
+func lineIP(line string) net.IP {
+	if ip := net.ParseIP(line); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(line); err == nil {
+		return net.ParseIP(host)
+	}
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end > 0 {
+			return net.ParseIP(line[1:end])
+		}
+	}
+	if host, _, ok := strings.Cut(line, ":"); ok {
+		return net.ParseIP(host)
+	}
+	return nil
+}
+
+// BlacklistUnion is a blacklist backend that matches a line if any of its backends does, e.g. to combine a
+// BlacklistManager of exact entries with a RuleBlacklist.
+type BlacklistUnion []BlacklistBackend
+
+// Has returns true if any backend has the line.
+func (u BlacklistUnion) Has(line string) bool {
+	for _, backend := range u {
+		if backend.Has(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := map[string]lizt.Rule{
+		"user@example.com":    {Kind: lizt.RuleExact, Pattern: "user@example.com"},
+		"exact:prefix:admin":  {Kind: lizt.RuleExact, Pattern: "prefix:admin"},
+		"prefix:admin":        {Kind: lizt.RulePrefix, Pattern: "admin"},
+		"suffix:@example.com": {Kind: lizt.RuleSuffix, Pattern: "@example.com"},
+		"glob:*.example.com":  {Kind: lizt.RuleGlob, Pattern: "*.example.com"},
+		"regex:^test\\d+$":    {Kind: lizt.RuleRegex, Pattern: "^test\\d+$"},
+		"cidr:10.0.0.0/8":     {Kind: lizt.RuleCIDR, Pattern: "10.0.0.0/8"},
+		"1.2.3.4:8080":        {Kind: lizt.RuleExact, Pattern: "1.2.3.4:8080"},
+	}
+	for line, expected := range tests {
+		rule, err := lizt.ParseRule(line)
+		if err != nil {
+			t.Errorf("ParseRule(%s) error = %v", line, err)
+		}
+		if rule != expected {
+			t.Errorf("ParseRule(%s) = %v, want %v", line, rule, expected)
+		}
+	}
+
+	if _, err := lizt.ParseRule("regex:"); !errors.Is(err, lizt.ErrInvalidRule) {
+		t.Errorf("wanted ErrInvalidRule, got error = %v", err)
+	}
+}
+
+func TestRuleBlacklist_Has(t *testing.T) {
+	rb, err := lizt.NewRuleBlacklist(
+		lizt.Rule{Kind: lizt.RuleExact, Pattern: "exact"},
+		lizt.Rule{Kind: lizt.RulePrefix, Pattern: "admin"},
+		lizt.Rule{Kind: lizt.RuleSuffix, Pattern: "@example.com"},
+		lizt.Rule{Kind: lizt.RuleGlob, Pattern: "*.example.org"},
+		lizt.Rule{Kind: lizt.RuleRegex, Pattern: `^test\d+$`},
+		lizt.Rule{Kind: lizt.RuleCIDR, Pattern: "10.0.0.0/8"},
+		lizt.Rule{Kind: lizt.RuleCIDR, Pattern: "2001:db8::/32"},
+	)
+	if err != nil {
+		t.Fatalf("NewRuleBlacklist() error = %v", err)
+	}
+
+	blacklisted := []string{
+		"exact", "admin1", "user@example.com", "www.example.org", "a.b.example.org", "test42",
+		"10.1.2.3", "10.1.2.3:8080", "10.1.2.3:8080:user:pass", "[2001:db8::1]:443",
+	}
+	for _, line := range blacklisted {
+		if !rb.Has(line) {
+			t.Errorf("expected %s to be blacklisted", line)
+		}
+	}
+
+	clean := []string{
+		"exact2", "user-admin", "user@example.com.au", "example.org", "test", "test42a",
+		"11.1.2.3:8080", "[2001:db9::1]:443", "10.1.2.3x",
+	}
+	for _, line := range clean {
+		if rb.Has(line) {
+			t.Errorf("expected %s not to be blacklisted", line)
+		}
+	}
+
+	if err = rb.Add(lizt.Rule{Kind: lizt.RuleRegex, Pattern: "("}); !errors.Is(err, lizt.ErrInvalidRule) {
+		t.Errorf("wanted ErrInvalidRule, got error = %v", err)
+	}
+	if err = rb.Add(lizt.Rule{Kind: lizt.RuleCIDR, Pattern: "10.0.0.0"}); !errors.Is(err, lizt.ErrInvalidRule) {
+		t.Errorf("wanted ErrInvalidRule, got error = %v", err)
+	}
+}
+
+func TestLoadRuleBlacklist(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.txt")
+	content := "# proxies in the office range\ncidr:192.168.0.0/16\n\nsuffix:.internal:3128\n1.1.1.1:80\n"
+	if err := os.WriteFile(rules, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	rb, err := lizt.LoadRuleBlacklist(rules)
+	if err != nil {
+		t.Fatalf("LoadRuleBlacklist() error = %v", err)
+	}
+	if len(rb.Rules()) != 3 {
+		t.Errorf("expected %d rules, got %v", 3, rb.Rules())
+	}
+
+	proxies := []string{"192.168.1.1:8080", "8.8.8.8:53", "proxy.internal:3128", "1.1.1.1:80", "9.9.9.9:9999"}
+	next, err := lizt.B().Slice(proxies).Blacklist(rb).MustBuild().Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"8.8.8.8:53", "9.9.9.9:9999"}) {
+		t.Errorf("expected %v, got %v", []string{"8.8.8.8:53", "9.9.9.9:9999"}, next)
+	}
+
+	source, dest := filepath.Join(dir, "proxies.txt"), filepath.Join(dir, "scrubbed.txt")
+	if err = lizt.WriteToFile(proxies, source); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+	union := lizt.BlacklistUnion{rb, lizt.BlacklistMap{"8.8.8.8:53": {}}}
+	n, err := lizt.ScrubFileWithBlacklist(union, source, dest)
+	if err != nil {
+		t.Errorf("ScrubFileWithBlacklist() error = %v", err)
+	}
+	if n != 4 {
+		t.Errorf("expected %d lines scrubbed, got %d", 4, n)
+	}
+
+	if err = os.WriteFile(rules, []byte("regex:[\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err = lizt.LoadRuleBlacklist(rules); !errors.Is(err, lizt.ErrInvalidRule) {
+		t.Errorf("wanted ErrInvalidRule, got error = %v", err)
+	}
+}
@@ -1,7 +1,14 @@
 package lizt_test
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 
 	"git.faze.center/netr/lizt"
 )
@@ -116,6 +123,18 @@ func TestBlacklistManager_ShouldWorkAsExpected(t *testing.T) {
 	}
 }
 
+func TestBlacklistManager_Add_ShouldAcceptAnyEntryInMemory(t *testing.T) {
+	blkMgr := lizt.NewBlacklistManager(lizt.BlacklistMap{})
+	for _, who := range []string{"", " x ", "a\nb", lizt.BlacklistTombstone + "a"} {
+		if err := blkMgr.Add(who); err != nil {
+			t.Errorf("Add(%q) error = %v", who, err)
+		}
+		if !blkMgr.Has(who) {
+			t.Errorf("Has(%q) = false, want true", who)
+		}
+	}
+}
+
 func TestBlacklistManager_Remove_ShouldWorkAsExpected(t *testing.T) {
 	blkMap := lizt.BlacklistMap{
 		"b": {}, "d": {}, "f": {}, "h": {}, "j": {},
@@ -145,3 +164,218 @@ func TestBlacklistManager_Remove_ShouldErrorRemovingItemThatsNotFound(t *testing
 		t.Errorf("Expected error, got nil")
 	}
 }
+
+func TestBlacklister_NextContext_ShouldStopSpinningOnDeadline(t *testing.T) {
+	numbers := []string{"1", "2", "3"}
+	blm := lizt.NewBlacklistManager(lizt.BlacklistMap{"1": {}, "2": {}, "3": {}})
+
+	blkIter, _ := lizt.B().SliceRR(numbers).Blacklist(blm).Build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := blkIter.NextContext(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestBlacklister_Next_Backends(t *testing.T) {
+	numbers := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	blacklisted := []string{"2", "4", "6", "8", "10"}
+
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := lizt.WriteToFile(blacklisted, path); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+	if err := lizt.SortBlacklistFile(path, path); err != nil {
+		t.Fatalf("SortBlacklistFile() error = %v", err)
+	}
+	sorted, err := lizt.NewSortedFileBlacklist(path)
+	if err != nil {
+		t.Fatalf("NewSortedFileBlacklist() error = %v", err)
+	}
+	defer sorted.Close()
+
+	source := filepath.Join(t.TempDir(), "numbers.txt")
+	if err := lizt.WriteToFile(numbers, source); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+
+	blkMap, err := lizt.FileToMap(path)
+	if err != nil {
+		t.Fatalf("FileToMap() error = %v", err)
+	}
+	bloom, err := lizt.FileToBloomFilter(path, 0.0001)
+	if err != nil {
+		t.Fatalf("FileToBloomFilter() error = %v", err)
+	}
+
+	backends := map[string]lizt.BlacklistBackend{
+		"map":     blkMap,
+		"manager": lizt.NewBlacklistManager(blkMap),
+		"bloom":   bloom,
+		"sorted":  sorted,
+	}
+	for name, backend := range backends {
+		next, err := lizt.B().Slice(numbers).Blacklist(backend).MustBuild().Next(5)
+		if err != nil {
+			t.Errorf("%s: Next() error = %v", name, err)
+		}
+		if !reflect.DeepEqual(next, []string{"1", "3", "5", "7", "9"}) {
+			t.Errorf("%s: expected %v, got %v", name, []string{"1", "3", "5", "7", "9"}, next)
+		}
+
+		dest := filepath.Join(t.TempDir(), "scrubbed.txt")
+		n, err := lizt.ScrubFileWithBlacklist(backend, source, dest)
+		if err != nil {
+			t.Errorf("%s: ScrubFileWithBlacklist() error = %v", name, err)
+		}
+		if n != 5 {
+			t.Errorf("%s: expected %d lines scrubbed, got %d", name, 5, n)
+		}
+	}
+}
+
+func TestFileBlacklistManager_ShouldSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+
+	blkMgr, err := lizt.NewFileBlacklistManager(path)
+	if err != nil {
+		t.Fatalf("NewFileBlacklistManager() error = %v", err)
+	}
+	for _, who := range []string{"a", "b", "c"} {
+		if err = blkMgr.Add(who); err != nil {
+			t.Errorf("Add(%s) error = %v", who, err)
+		}
+	}
+	if err = blkMgr.Remove("a"); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	// no Close, as if the process crashed.
+
+	reopened, err := lizt.NewFileBlacklistManager(path)
+	if err != nil {
+		t.Fatalf("NewFileBlacklistManager() error = %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Has("a") || !reopened.Has("b") || !reopened.Has("c") {
+		t.Errorf("expected [b c], got %v", reopened.ToStringSlice())
+	}
+	if reopened.Dead() != 2 {
+		t.Errorf("expected %d dead lines, got %d", 2, reopened.Dead())
+	}
+
+	if err = reopened.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if err = reopened.Add("d"); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+
+	lines, err := lizt.ReadFromFile(path)
+	if err != nil {
+		t.Fatalf("ReadFromFile() error = %v", err)
+	}
+	sort.Strings(lines)
+	if !reflect.DeepEqual(lines, []string{"b", "c", "d"}) {
+		t.Errorf("expected %v, got %v", []string{"b", "c", "d"}, lines)
+	}
+	if reopened.Dead() != 0 {
+		t.Errorf("expected %d dead lines, got %d", 0, reopened.Dead())
+	}
+}
+
+func TestFileBlacklistManager_Add_ShouldRejectEntriesThatDontSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+
+	blkMgr, err := lizt.NewFileBlacklistManager(path)
+	if err != nil {
+		t.Fatalf("NewFileBlacklistManager() error = %v", err)
+	}
+	for _, who := range []string{"a", "b"} {
+		if err = blkMgr.Add(who); err != nil {
+			t.Errorf("Add(%s) error = %v", who, err)
+		}
+	}
+	for _, who := range []string{" c", "c ", "\t", "", lizt.BlacklistTombstone + "a"} {
+		if err = blkMgr.Add(who); err == nil {
+			t.Errorf("Add(%q): expected an error", who)
+		}
+	}
+	_ = blkMgr.Close()
+
+	reopened, err := lizt.NewFileBlacklistManager(path)
+	if err != nil {
+		t.Fatalf("NewFileBlacklistManager() error = %v", err)
+	}
+	defer reopened.Close()
+
+	items := reopened.ToStringSlice()
+	sort.Strings(items)
+	if !reflect.DeepEqual(items, []string{"a", "b"}) {
+		t.Errorf("expected %v after reload, got %q", []string{"a", "b"}, items)
+	}
+}
+
+func TestFileBlacklistManager_Normalize_ShouldKeepRemovedEntriesRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(path, []byte("Foo@X\nbar@x\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	blkMgr, err := lizt.NewFileBlacklistManager(path)
+	if err != nil {
+		t.Fatalf("NewFileBlacklistManager() error = %v", err)
+	}
+	if err = blkMgr.Normalize(lizt.NormalizeFoldCase).Remove("foo@x"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	_ = blkMgr.Close()
+
+	for i := 0; i < 2; i++ {
+		reopened, err := lizt.NewFileBlacklistManager(path)
+		if err != nil {
+			t.Fatalf("NewFileBlacklistManager() error = %v", err)
+		}
+		reopened.Normalize(lizt.NormalizeFoldCase)
+		if reopened.Has("foo@x") || !reopened.Has("BAR@x") {
+			t.Errorf("restart %d: expected [bar@x], got %v", i+1, reopened.ToStringSlice())
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestFileBlacklistManager_ShouldFixMissingNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(path, []byte("a\nb"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	blkMgr, err := lizt.NewFileBlacklistManager(path)
+	if err != nil {
+		t.Fatalf("NewFileBlacklistManager() error = %v", err)
+	}
+	if err = blkMgr.Add("c"); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+	_ = blkMgr.Close()
+
+	blkMap, err := lizt.FileToMap(path)
+	if err != nil {
+		t.Fatalf("FileToMap() error = %v", err)
+	}
+	for _, who := range []string{"a", "b", "c"} {
+		if !blkMap.Has(who) {
+			t.Errorf("expected %s to be in the file", who)
+		}
+	}
+}
+
+func TestFileBlacklistManager_Compressed(t *testing.T) {
+	_, err := lizt.NewFileBlacklistManager(copyToTemp(t, "test/compressed/gzip.txt.gz"))
+	if !errors.Is(err, lizt.ErrCompressed) {
+		t.Errorf("wanted ErrCompressed, got error = %v", err)
+	}
+}
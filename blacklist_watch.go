@@ -0,0 +1,192 @@
+package lizt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBlacklistWatchInterval is the polling interval used when BlacklistWatcherConfig.Interval is zero.
+var DefaultBlacklistWatchInterval = 5 * time.Second
+
+// BlacklistChange reports how a reload changed the watched blacklist.
+type BlacklistChange struct {
+	Files   []string
+	Added   []string
+	Removed []string
+}
+
+// BlacklistWatcher polls blacklist files and swaps the set of a BlacklistManager whenever one of them changes.
+// BlacklistingIterators that use the manager see the new set from their next line on.
+type BlacklistWatcher struct {
+	manager  *BlacklistManager
+	files    []string
+	stats    map[string]os.FileInfo
+	maps     map[string]BlacklistMap
+	items    BlacklistMap
+	interval time.Duration
+	onChange func(BlacklistChange)
+	onError  func(error)
+	stop     chan struct{}
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+// BlacklistWatcherConfig is the config for a blacklist watcher. The Manager is optional, a new one is created if
+// it's nil. It shouldn't be file-backed, since its set is replaced on every reload. OnChange is called after a
+// reload that added or removed entries, and OnError when a file can't be read, in which case the last good
+// entries of that file are kept while the other files are still reloaded.
+type BlacklistWatcherConfig struct {
+	Files    []string
+	Manager  *BlacklistManager
+	Interval time.Duration
+	OnChange func(BlacklistChange)
+	OnError  func(error)
+}
+
+// NewBlacklistWatcher returns a new blacklist watcher with the files already loaded into the manager. Call Start to
+// begin polling.
+func NewBlacklistWatcher(cfg BlacklistWatcherConfig) (*BlacklistWatcher, error) {
+	bw := &BlacklistWatcher{
+		manager:  cfg.Manager,
+		files:    cfg.Files,
+		stats:    make(map[string]os.FileInfo),
+		maps:     make(map[string]BlacklistMap),
+		interval: cfg.Interval,
+		onChange: cfg.OnChange,
+		onError:  cfg.OnError,
+	}
+	if bw.manager == nil {
+		bw.manager = NewBlacklistManager(make(BlacklistMap))
+	}
+	if bw.interval <= 0 {
+		bw.interval = DefaultBlacklistWatchInterval
+	}
+
+	if _, err := bw.Check(); err != nil {
+		return nil, err
+	}
+	return bw, nil
+}
+
+// Manager returns the manager whose set is swapped on reload.
+func (bw *BlacklistWatcher) Manager() *BlacklistManager {
+	return bw.manager
+}
+
+// Check reloads the files that changed since the last check and swaps the set if any did. It returns the change,
+// which is empty if nothing changed. A file that can't be read keeps its last good entries and is retried on the
+// next check, the files that loaded are swapped in regardless; the first such error is returned with the change.
+func (bw *BlacklistWatcher) Check() (BlacklistChange, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	var (
+		change   BlacklistChange
+		firstErr error
+	)
+	for _, file := range bw.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("blacklist: watch: %s -> %w", file, err)
+			}
+			continue
+		}
+		if last, ok := bw.stats[file]; ok && last.Size() == info.Size() && last.ModTime().Equal(info.ModTime()) {
+			continue
+		}
+
+		m, err := FileToMap(file)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("blacklist: watch: %s -> %w", file, err)
+			}
+			continue
+		}
+		delete(m, "")
+
+		bw.stats[file] = info
+		bw.maps[file] = m
+		change.Files = append(change.Files, file)
+	}
+	if len(change.Files) == 0 {
+		return change, firstErr
+	}
+
+	items := make(BlacklistMap)
+	for _, m := range bw.maps {
+		for item := range m {
+			items[item] = struct{}{}
+		}
+	}
+
+	old := bw.items
+	bw.items = items
+	bw.manager.Swap(items)
+	for item := range items {
+		if _, ok := old[item]; !ok {
+			change.Added = append(change.Added, item)
+		}
+	}
+	for item := range old {
+		if _, ok := items[item]; !ok {
+			change.Removed = append(change.Removed, item)
+		}
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+
+	return change, firstErr
+}
+
+// Start starts polling in the background.
+func (bw *BlacklistWatcher) Start() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.stop != nil {
+		return
+	}
+	bw.stop = make(chan struct{})
+	bw.done = make(chan struct{})
+	go bw.poll(bw.stop, bw.done)
+}
+
+func (bw *BlacklistWatcher) poll(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(bw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			change, err := bw.Check()
+			if err != nil && bw.onError != nil {
+				bw.onError(err)
+			}
+			if bw.onChange != nil && (len(change.Added) > 0 || len(change.Removed) > 0) {
+				bw.onChange(change)
+			}
+		}
+	}
+}
+
+// Stop stops polling and waits for a running check to finish.
+func (bw *BlacklistWatcher) Stop() {
+	bw.mu.Lock()
+	stop, done := bw.stop, bw.done
+	bw.stop, bw.done = nil, nil
+	bw.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,135 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+// writeBlacklist writes lines to a file and bumps its mtime, so a change is noticed even on coarse file systems.
+func writeBlacklist(t *testing.T, path string, lines []string, mtime time.Time) {
+	t.Helper()
+
+	if err := lizt.WriteToFile(lines, path); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+}
+
+func TestBlacklistWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	start := time.Now().Add(-time.Hour)
+	writeBlacklist(t, first, []string{"1", "2"}, start)
+	writeBlacklist(t, second, []string{"3"}, start)
+
+	bw, err := lizt.NewBlacklistWatcher(lizt.BlacklistWatcherConfig{Files: []string{first, second}})
+	if err != nil {
+		t.Fatalf("NewBlacklistWatcher() error = %v", err)
+	}
+	iter := lizt.B().SliceRR([]string{"1", "2", "3", "4", "5"}).Blacklist(bw.Manager()).MustBuild()
+
+	if next := iter.MustNext(2); !reflect.DeepEqual(next, []string{"4", "5"}) {
+		t.Errorf("expected %v, got %v", []string{"4", "5"}, next)
+	}
+
+	change, err := bw.Check()
+	if err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if len(change.Files) != 0 {
+		t.Errorf("expected no change, got %v", change)
+	}
+
+	writeBlacklist(t, first, []string{"2", "4"}, start.Add(time.Minute))
+	change, err = bw.Check()
+	if err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	expected := lizt.BlacklistChange{Files: []string{first}, Added: []string{"4"}, Removed: []string{"1"}}
+	if !reflect.DeepEqual(change, expected) {
+		t.Errorf("expected %v, got %v", expected, change)
+	}
+
+	if next := iter.MustNext(3); !reflect.DeepEqual(next, []string{"1", "5", "1"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "5", "1"}, next)
+	}
+}
+
+func TestBlacklistWatcher_Start(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	start := time.Now().Add(-time.Hour)
+	writeBlacklist(t, path, []string{"a"}, start)
+
+	changes := make(chan lizt.BlacklistChange, 1)
+	bw, err := lizt.NewBlacklistWatcher(lizt.BlacklistWatcherConfig{
+		Files:    []string{path},
+		Interval: 5 * time.Millisecond,
+		OnChange: func(change lizt.BlacklistChange) { changes <- change },
+	})
+	if err != nil {
+		t.Fatalf("NewBlacklistWatcher() error = %v", err)
+	}
+	bw.Start()
+	defer bw.Stop()
+
+	writeBlacklist(t, path, []string{"a", "b"}, start.Add(time.Minute))
+
+	select {
+	case change := <-changes:
+		if !reflect.DeepEqual(change.Added, []string{"b"}) || len(change.Removed) != 0 {
+			t.Errorf("expected b to be added, got %v", change)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected a change to be reported")
+	}
+	if !bw.Manager().Has("b") {
+		t.Errorf("expected b to be blacklisted")
+	}
+}
+
+func TestBlacklistWatcher_MissingFile(t *testing.T) {
+	_, err := lizt.NewBlacklistWatcher(lizt.BlacklistWatcherConfig{Files: []string{filepath.Join(t.TempDir(), "nope.txt")}})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("wanted os.ErrNotExist, got error = %v", err)
+	}
+}
+
+func TestBlacklistWatcher_Check_ShouldSwapFilesThatLoaded(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	start := time.Now().Add(-time.Hour)
+	writeBlacklist(t, first, []string{"1"}, start)
+	writeBlacklist(t, second, []string{"2"}, start)
+
+	bw, err := lizt.NewBlacklistWatcher(lizt.BlacklistWatcherConfig{Files: []string{first, second}})
+	if err != nil {
+		t.Fatalf("NewBlacklistWatcher() error = %v", err)
+	}
+
+	if err = os.Remove(first); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	writeBlacklist(t, second, []string{"2", "3"}, start.Add(time.Minute))
+
+	change, err := bw.Check()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("wanted os.ErrNotExist, got error = %v", err)
+	}
+	expected := lizt.BlacklistChange{Files: []string{second}, Added: []string{"3"}}
+	if !reflect.DeepEqual(change, expected) {
+		t.Errorf("expected %v, got %v", expected, change)
+	}
+	for _, item := range []string{"1", "2", "3"} {
+		if !bw.Manager().Has(item) {
+			t.Errorf("expected %s to be blacklisted", item)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package lizt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync"
+)
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter")
+
+// bloomMagic identifies (and versions) a saved bloom filter.
+var bloomMagic = [8]byte{'L', 'I', 'Z', 'T', 'B', 'L', 'M', '1'}
+
+// BloomFilter is a probabilistic set. Has never reports false for an added line, and reports true for a line that
+// was never added at roughly the false-positive rate it was sized for.
+type BloomFilter struct {
+	bits  []uint64
+	m     uint64
+	k     uint64
+	added uint64
+	mu    sync.RWMutex
+}
+
+// NewBloomFilter returns a bloom filter sized for n lines at the given false-positive rate.
+func NewBloomFilter(n uint64, fpRate float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// bloomHashes returns the two base hashes used for double hashing.
+func bloomHashes(line string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(line))
+	h1 := h.Sum64()
+	return h1, mix64(h1) | 1
+}
+
+// Add adds a line.
+func (bf *BloomFilter) Add(line string) {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	bf.add(line)
+}
+
+func (bf *BloomFilter) add(line string) bool {
+	h1, h2 := bloomHashes(line)
+	present := true
+	for i := uint64(0); i < bf.k; i++ {
+		bit := (h1 + i*h2) % bf.m
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			present = false
+			bf.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	if !present {
+		bf.added++
+	}
+	return present
+}
+
+// TestAndAdd adds a line and returns true if it was (probably) already present.
+func (bf *BloomFilter) TestAndAdd(line string) bool {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	return bf.add(line)
+}
+
+// Has returns true if the line was (probably) added.
+func (bf *BloomFilter) Has(line string) bool {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	h1, h2 := bloomHashes(line)
+	for i := uint64(0); i < bf.k; i++ {
+		bit := (h1 + i*h2) % bf.m
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of lines that were added and weren't already (probably) present.
+func (bf *BloomFilter) Len() int {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	return int(bf.added)
+}
+
+// WriteTo writes the bloom filter to w.
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	buf := bytes.NewBuffer(make([]byte, 0, 8+8*4+8*len(bf.bits)))
+	buf.Write(bloomMagic[:])
+	for _, v := range []uint64{bf.m, bf.k, bf.added, uint64(len(bf.bits))} {
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	}
+	for _, word := range bf.bits {
+		_ = binary.Write(buf, binary.LittleEndian, word)
+	}
+
+	n, err := w.Write(buf.Bytes())
+	if err != nil {
+		return int64(n), fmt.Errorf("bloom: write -> %w", err)
+	}
+	return int64(n), nil
+}
+
+// ReadFrom replaces the bloom filter with one written by WriteTo.
+func (bf *BloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	var header [8 + 8*4]byte
+	n, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return int64(n), fmt.Errorf("bloom: read -> %w", err)
+	}
+	if !bytes.Equal(header[:8], bloomMagic[:]) {
+		return int64(n), fmt.Errorf("bloom: %w", ErrInvalidBloomFilter)
+	}
+
+	m := binary.LittleEndian.Uint64(header[8:])
+	k := binary.LittleEndian.Uint64(header[16:])
+	added := binary.LittleEndian.Uint64(header[24:])
+	words := binary.LittleEndian.Uint64(header[32:])
+	if m == 0 || k == 0 || words != (m+63)/64 {
+		return int64(n), fmt.Errorf("bloom: %w", ErrInvalidBloomFilter)
+	}
+
+	// read before allocating, so a corrupt header can't make us allocate more than the input holds
+	data, err := io.ReadAll(io.LimitReader(r, int64(words)*8))
+	if err != nil {
+		return int64(n + len(data)), fmt.Errorf("bloom: read -> %w", err)
+	}
+	if uint64(len(data)) != words*8 {
+		return int64(n + len(data)), fmt.Errorf("bloom: read -> %w", io.ErrUnexpectedEOF)
+	}
+	bits := make([]uint64, words)
+	for i := range bits {
+		bits[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	bf.bits, bf.m, bf.k, bf.added = bits, m, k, added
+	return int64(n) + int64(words)*8, nil
+}
@@ -0,0 +1,94 @@
+package lizt_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestBloomFilter_Has(t *testing.T) {
+	bf := lizt.NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		bf.Add(strconv.Itoa(i))
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !bf.Has(strconv.Itoa(i)) {
+			t.Fatalf("expected %d to be present", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if bf.Has(strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("expected roughly 1%% false positives, got %d of 10000", falsePositives)
+	}
+}
+
+func TestBloomFilter_TestAndAdd(t *testing.T) {
+	bf := lizt.NewBloomFilter(10, 0.01)
+	if bf.TestAndAdd("a") {
+		t.Errorf("expected a to be new")
+	}
+	if !bf.TestAndAdd("a") {
+		t.Errorf("expected a to be present")
+	}
+	if bf.Len() != 1 {
+		t.Errorf("expected %d, got %d", 1, bf.Len())
+	}
+}
+
+func TestBloomFilter_WriteTo_ReadFrom(t *testing.T) {
+	bf := lizt.NewBloomFilter(100, 0.01)
+	for _, line := range hundredLines() {
+		bf.Add(line)
+	}
+
+	var buf bytes.Buffer
+	if _, err := bf.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	loaded := &lizt.BloomFilter{}
+	if _, err := loaded.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	for _, line := range hundredLines() {
+		if !loaded.Has(line) {
+			t.Errorf("expected %s to be present after loading", line)
+		}
+	}
+	if loaded.Len() != bf.Len() {
+		t.Errorf("expected %d, got %d", bf.Len(), loaded.Len())
+	}
+
+	_, err := loaded.ReadFrom(bytes.NewReader([]byte("not a bloom filter, but long enough to have a header")))
+	if !errors.Is(err, lizt.ErrInvalidBloomFilter) {
+		t.Errorf("wanted ErrInvalidBloomFilter, got error = %v", err)
+	}
+}
+
+func TestBloomFilter_ReadFrom_ShouldNotTrustTheHeaderSize(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := lizt.NewBloomFilter(10, 0.01).WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	// claim 2^40 bits, i.e. 128GB of words, in a filter of a few bytes
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint64(data[8:], 1<<40)
+	binary.LittleEndian.PutUint64(data[32:], 1<<40/64)
+
+	if _, err := (&lizt.BloomFilter{}).ReadFrom(bytes.NewReader(data)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("wanted io.ErrUnexpectedEOF, got error = %v", err)
+	}
+}
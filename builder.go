@@ -44,6 +44,46 @@ func (ib *PointerIteratorBuilder) StreamRR(path string) *PointerIteratorBuilder
 	return ib
 }
 
+// StreamIndexed creates a new StreamIterator backed by a line-offset index, building the index if needed.
+func (ib *PointerIteratorBuilder) StreamIndexed(path string) *PointerIteratorBuilder {
+	stream, err := NewIndexedStreamIterator(path, false)
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = stream
+	return ib
+}
+
+// StreamIndexedRR creates a new StreamIterator backed by a line-offset index with round-robin.
+func (ib *PointerIteratorBuilder) StreamIndexedRR(path string) *PointerIteratorBuilder {
+	stream, err := NewIndexedStreamIterator(path, true)
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = stream
+	return ib
+}
+
+// Mmap creates a new MmapIterator.
+func (ib *PointerIteratorBuilder) Mmap(path string) *PointerIteratorBuilder {
+	mmap, err := NewMmapIterator(path, false)
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = mmap
+	return ib
+}
+
+// MmapRR creates a new MmapIterator with round-robin.
+func (ib *PointerIteratorBuilder) MmapRR(path string) *PointerIteratorBuilder {
+	mmap, err := NewMmapIterator(path, true)
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = mmap
+	return ib
+}
+
 // Slice creates a new SliceIterator. Note that this randomizes the name and won't work while using a Manager. Use SliceNamed instead.
 func (ib *PointerIteratorBuilder) Slice(lines []string) *PointerIteratorBuilder {
 	ib.listIter = NewSliceIterator(randomString(8), lines, false)
@@ -68,8 +108,120 @@ func (ib *PointerIteratorBuilder) SliceNamedRR(name string, lines []string) *Poi
 	return ib
 }
 
+// Shuffle wraps the iterator in a ShufflingIterator with the given seed.
+func (ib *PointerIteratorBuilder) Shuffle(seed int64) *PointerIteratorBuilder {
+	shuffle, err := NewShufflingIterator(ShufflingIteratorConfig{
+		PointerIter: ib.listIter,
+		Seed:        seed,
+	})
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = shuffle
+	return ib
+}
+
+// ShuffleRR wraps the iterator in a round-robin ShufflingIterator with the given seed. With reshuffle, every cycle
+// gets a new permutation.
+func (ib *PointerIteratorBuilder) ShuffleRR(seed int64, reshuffle bool) *PointerIteratorBuilder {
+	shuffle, err := NewShufflingIterator(ShufflingIteratorConfig{
+		PointerIter: ib.listIter,
+		Seed:        seed,
+		RoundRobin:  true,
+		Reshuffle:   reshuffle,
+	})
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = shuffle
+	return ib
+}
+
+// Shard wraps the iterator in a ShardingIterator that serves the lines whose index % count == index.
+func (ib *PointerIteratorBuilder) Shard(index, count int) *PointerIteratorBuilder {
+	return ib.shard(index, count, ShardModulo, false)
+}
+
+// ShardRR wraps the iterator in a round-robin ShardingIterator that serves the lines whose index % count == index.
+func (ib *PointerIteratorBuilder) ShardRR(index, count int) *PointerIteratorBuilder {
+	return ib.shard(index, count, ShardModulo, true)
+}
+
+// ShardBlocks wraps the iterator in a ShardingIterator that serves the index-th of count contiguous blocks.
+func (ib *PointerIteratorBuilder) ShardBlocks(index, count int) *PointerIteratorBuilder {
+	return ib.shard(index, count, ShardBlocks, false)
+}
+
+// ShardBlocksRR wraps the iterator in a round-robin ShardingIterator that serves the index-th of count contiguous
+// blocks.
+func (ib *PointerIteratorBuilder) ShardBlocksRR(index, count int) *PointerIteratorBuilder {
+	return ib.shard(index, count, ShardBlocks, true)
+}
+
+func (ib *PointerIteratorBuilder) shard(index, count int, mode ShardMode, roundRobin bool) *PointerIteratorBuilder {
+	shard, err := NewShardingIterator(ShardingIteratorConfig{
+		PointerIter: ib.listIter,
+		Index:       index,
+		Count:       count,
+		Mode:        mode,
+		RoundRobin:  roundRobin,
+	})
+	if err != nil {
+		panic(err)
+	}
+	ib.listIter = shard
+	return ib
+}
+
+// Dedupe wraps the iterator in a DedupingIterator that remembers every emitted line exactly.
+func (ib *PointerIteratorBuilder) Dedupe() *PointerIteratorBuilder {
+	ib.listIter = NewDedupingIterator(DedupingIteratorConfig{
+		PointerIter: ib.listIter,
+		Mode:        DedupeExact,
+	})
+	return ib
+}
+
+// DedupeApprox wraps the iterator in a DedupingIterator backed by a bloom filter with the given false-positive rate.
+func (ib *PointerIteratorBuilder) DedupeApprox(fpRate float64) *PointerIteratorBuilder {
+	ib.listIter = NewDedupingIterator(DedupingIteratorConfig{
+		PointerIter:       ib.listIter,
+		Mode:              DedupeApprox,
+		FalsePositiveRate: fpRate,
+	})
+	return ib
+}
+
+// Filter wraps the iterator in a FilterIterator, so only lines for which fn returns true are emitted.
+func (ib *PointerIteratorBuilder) Filter(fn func(string) bool) *PointerIteratorBuilder {
+	ib.listIter = NewFilterIterator(FilterIteratorConfig{
+		PointerIter: ib.listIter,
+		Fn:          fn,
+	})
+	return ib
+}
+
+// Map wraps the iterator in a MapIterator, so every emitted line is transformed by fn.
+func (ib *PointerIteratorBuilder) Map(fn func(string) string) *PointerIteratorBuilder {
+	ib.listIter = NewMapIterator(MapIteratorConfig{
+		PointerIter: ib.listIter,
+		Fn:          fn,
+	})
+	return ib
+}
+
+// Normalize wraps the iterator in a NormalizingIterator, so emitted lines are normalized. A blacklist sees the
+// normalized lines as well.
+func (ib *PointerIteratorBuilder) Normalize(ns ...Normalizer) *PointerIteratorBuilder {
+	ib.listIter = NewNormalizingIterator(NormalizingIteratorConfig{
+		PointerIter: ib.listIter,
+		Normalizer:  ChainNormalizers(ns...),
+	})
+	return ib
+}
+
 // Blacklist creates a new BlacklistingIterator
-func (ib *PointerIteratorBuilder) Blacklist(bl *BlacklistManager) *PointerIteratorBuilder {
+func (ib *PointerIteratorBuilder) Blacklist(bl BlacklistBackend) *PointerIteratorBuilder {
 	var err error
 	ib.blacklistIter, err = NewBlacklistingIterator(BlacklistingIteratorConfig{
 		PointerIter: ib.listIter,
@@ -172,7 +324,7 @@ func (ib *PointerIteratorBuilder) PersistTo(p Persister) *PersistentIteratorBuil
 }
 
 // Blacklist creates a new BlacklistingIterator
-func (ib *PersistentIteratorBuilder) Blacklist(bl *BlacklistManager) *PersistentIteratorBuilder {
+func (ib *PersistentIteratorBuilder) Blacklist(bl BlacklistBackend) *PersistentIteratorBuilder {
 	var err error
 	ib.blacklistIter, err = NewBlacklistingIterator(BlacklistingIteratorConfig{
 		PointerIter: ib.listIter,
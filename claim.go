@@ -0,0 +1,369 @@
+package lizt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultClaimRangeSize is the number of lines a ClaimingIterator claims at a time when RangeSize is zero.
+var DefaultClaimRangeSize = 1000
+
+// claimDone is the lease of a range whose lines have all been served.
+const claimDone = math.MaxUint64
+
+// claimPosBits is the number of low bits of a lease that hold the position in the range. The high bits hold the
+// deadline in Unix milliseconds, which fits until 2109.
+const claimPosBits = 22
+
+// MaxClaimRangeSize is the largest RangeSize of a ClaimingIterator, since the position in a range is stored in the
+// low claimPosBits of its lease.
+const MaxClaimRangeSize = 1<<claimPosBits - 1
+
+// ClaimingIterator lets several processes work through one list without serving a line twice. They share a
+// Persister, from which each claims a range of RangeSize lines at a time and serves it locally.
+//
+// A claimed range is leased until a deadline, which every call to Next renews together with the position in the
+// range, in one CompareAndSwap of a lease that holds both. Once a lease expires, e.g. because the worker crashed,
+// another worker reclaims the range and continues at the recorded position. A worker whose lease was taken over
+// drops the lines it read instead of returning them, so a line is returned at most once. Ranges whose lines have all
+// been served are marked done.
+//
+// The persister keys are "<name>.claims.next", "<name>.claims.done", and "<name>.claims.<i>.lease" for every range
+// i. The wrapped iterator must support SetPointer and shouldn't be round-robin. The position in a range is the
+// wrapped pointer, so wrappers that skip lines, such as a FilterIterator, work too.
+type ClaimingIterator struct {
+	PointerIterator
+	persister Persister
+	size      uint64
+	ranges    uint64
+	timeout   time.Duration
+	cur       *claimedRange
+	mu        sync.Mutex
+}
+
+// claimedRange is the range a ClaimingIterator currently owns. The lease holds token as long as it's owned.
+type claimedRange struct {
+	index uint64
+	token uint64
+	pos   uint64
+	len   uint64
+}
+
+// ClaimingIteratorConfig is the config for a claiming iterator. RangeSize defaults to DefaultClaimRangeSize, and can
+// be at most MaxClaimRangeSize, and Timeout defaults to DefaultLeaseTimeout.
+type ClaimingIteratorConfig struct {
+	PointerIter PointerIterator
+	Persister   Persister
+	RangeSize   int
+	Timeout     time.Duration
+}
+
+// NewClaimingIterator returns a new claiming iterator. It doesn't claim a range until the first call to Next.
+func NewClaimingIterator(cfg ClaimingIteratorConfig) (*ClaimingIterator, error) {
+	if cfg.Persister == nil {
+		return nil, fmt.Errorf("claim: name: %s -> persister is nil", cfg.PointerIter.Name())
+	}
+
+	size := cfg.RangeSize
+	if size <= 0 {
+		size = DefaultClaimRangeSize
+	}
+	if size > MaxClaimRangeSize {
+		return nil, fmt.Errorf("claim: name: %s -> range size %d is over %d", cfg.PointerIter.Name(), size, MaxClaimRangeSize)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultLeaseTimeout
+	}
+
+	total := uint64(cfg.PointerIter.Len())
+	return &ClaimingIterator{
+		PointerIterator: cfg.PointerIter,
+		persister:       cfg.Persister,
+		size:            uint64(size),
+		ranges:          (total + uint64(size) - 1) / uint64(size),
+		timeout:         timeout,
+	}, nil
+}
+
+func (ci *ClaimingIterator) key(suffix string) string {
+	return ci.Name() + ".claims." + suffix
+}
+
+func (ci *ClaimingIterator) leaseKey(i uint64) string {
+	return ci.key(fmt.Sprintf("%d.lease", i))
+}
+
+// value returns the value of a key, a missing key being 0. Only corrupt state is an error: reading a wrong value
+// is harmless, because every claim is a CompareAndSwap.
+func (ci *ClaimingIterator) value(key string) (uint64, error) {
+	val, err := ci.persister.Get(key)
+	if errors.Is(err, ErrCorruptState) {
+		return 0, fmt.Errorf("claim: name: %s -> %w", ci.Name(), err)
+	}
+	if err != nil {
+		return 0, nil
+	}
+	return val, nil
+}
+
+// token returns a new lease of the position pos, which expires after the timeout.
+func (ci *ClaimingIterator) token(pos uint64) uint64 {
+	deadline := uint64(time.Now().Add(ci.timeout).UnixMilli())
+	return deadline<<claimPosBits | pos
+}
+
+// leaseDeadline returns the deadline of a lease in Unix milliseconds.
+func leaseDeadline(lease uint64) uint64 {
+	return lease >> claimPosBits
+}
+
+// leasePos returns the position in the range of a lease.
+func leasePos(lease uint64) uint64 {
+	return lease & MaxClaimRangeSize
+}
+
+// claim takes over a range whose lease expired or, if there's none, claims the next fresh range. It returns
+// ErrNoMoreLines if every range is done or leased by another worker.
+func (ci *ClaimingIterator) claim() (*claimedRange, error) {
+	done, err := ci.value(ci.key("done"))
+	if err != nil {
+		return nil, err
+	}
+	next, err := ci.value(ci.key("next"))
+	if err != nil {
+		return nil, err
+	}
+	if next > ci.ranges {
+		next = ci.ranges
+	}
+
+	now := uint64(time.Now().UnixMilli())
+	for i := done; i < next; i++ {
+		lease, err := ci.value(ci.leaseKey(i))
+		if err != nil {
+			return nil, err
+		}
+		if lease == claimDone {
+			if i == done {
+				if ok, _ := ci.persister.CompareAndSwap(ci.key("done"), done, done+1); ok {
+					done++
+				}
+			}
+			continue
+		}
+		if leaseDeadline(lease) >= now {
+			continue
+		}
+		if r, err := ci.take(i, lease); r != nil || err != nil {
+			return r, err
+		}
+	}
+
+	for {
+		end, err := ci.persister.Increment(ci.key("next"), 1)
+		if err != nil {
+			return nil, fmt.Errorf("claim: name: %s -> %w", ci.Name(), err)
+		}
+		if end > ci.ranges {
+			return nil, ErrNoMoreLines
+		}
+		if r, err := ci.take(end-1, 0); r != nil || err != nil {
+			return r, err
+		}
+	}
+}
+
+// take claims range i, at the position recorded in its lease, if the lease is still lease. It returns nil if
+// another worker was faster.
+func (ci *ClaimingIterator) take(i, lease uint64) (*claimedRange, error) {
+	pos := leasePos(lease)
+	token := ci.token(pos)
+	ok, err := ci.persister.CompareAndSwap(ci.leaseKey(i), lease, token)
+	if err != nil {
+		return nil, fmt.Errorf("claim: name: %s -> %w", ci.Name(), err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	length := ci.size
+	if start := i * ci.size; start+length > uint64(ci.Len()) {
+		length = uint64(ci.Len()) - start
+	}
+	return &claimedRange{index: i, token: token, pos: pos, len: length}, nil
+}
+
+// commit renews the lease of the current range with the new position. It reports false if the lease was taken
+// over, in which case the lines read since the last commit must be dropped.
+func (ci *ClaimingIterator) commit(pos uint64) (bool, error) {
+	r := ci.cur
+	token := ci.token(pos)
+	if pos == r.len {
+		token = claimDone
+	}
+
+	ok, err := ci.persister.CompareAndSwap(ci.leaseKey(r.index), r.token, token)
+	if err != nil || !ok {
+		return false, err
+	}
+	r.token = token
+	r.pos = pos
+	return true, nil
+}
+
+// Next returns the next lines from the claimed ranges.
+func (ci *ClaimingIterator) Next(count int) ([]string, error) {
+	return ci.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines from the claimed ranges, claiming new ranges as they run out. It returns the
+// lines it got and ErrNoMoreLines once there's no range left to claim; ranges leased by other workers may become
+// claimable later, if their leases expire.
+func (ci *ClaimingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	var lines []string
+	for len(lines) < count {
+		if err := contextErr(ctx, ci.Name()); err != nil {
+			return lines, err
+		}
+
+		if ci.cur == nil {
+			r, err := ci.claim()
+			if errors.Is(err, ErrNoMoreLines) {
+				break
+			}
+			if err != nil {
+				return lines, err
+			}
+			ci.cur = r
+		}
+
+		r := ci.cur
+		n := r.len - r.pos
+		if want := uint64(count - len(lines)); want < n {
+			n = want
+		}
+		next, pos, nextErr := ci.read(ctx, r, n)
+
+		ok, err := ci.commit(pos)
+		if err != nil {
+			return lines, fmt.Errorf("claim: name: %s -> %w", ci.Name(), err)
+		}
+		if !ok {
+			// another worker took the range over
+			ci.cur = nil
+			continue
+		}
+		lines = append(lines, next...)
+		if r.pos == r.len {
+			ci.cur = nil
+		}
+
+		if nextErr != nil {
+			return lines, fmt.Errorf("claim: name: %s -> %w", ci.Name(), nextErr)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("claim: name: %s -> %w", ci.Name(), ErrNoMoreLines)
+	}
+	return lines, nil
+}
+
+// read reads up to n lines of range r and returns them with the new position in the range, which is where the
+// wrapped pointer stopped. Lines are read one at a time, so a wrapper that skips lines, e.g. a FilterIterator, can't
+// serve lines from past the end of the range: a line it reads from there is dropped and the range is done.
+func (ci *ClaimingIterator) read(ctx context.Context, r *claimedRange, n uint64) ([]string, uint64, error) {
+	start := r.index * ci.size
+	end := start + r.len
+	if p := start + r.pos; ci.PointerIterator.Pointer() != p {
+		ci.PointerIterator.SetPointer(p)
+	}
+
+	var lines []string
+	var err error
+	for uint64(len(lines)) < n {
+		var next []string
+		next, err = ci.PointerIterator.NextContext(ctx, 1)
+		if len(next) == 0 && err == nil {
+			err = ErrNoMoreLines
+		}
+		if err != nil || ci.PointerIterator.Pointer() > end {
+			break
+		}
+		lines = append(lines, next...)
+		if ci.PointerIterator.Pointer() == end {
+			break
+		}
+	}
+
+	pos := r.pos
+	if p := ci.PointerIterator.Pointer(); p > start+pos {
+		pos = p - start
+	}
+	if pos >= r.len {
+		pos = r.len
+		// every line of the range was read or skipped, so the range is done rather than the list
+		if errors.Is(err, ErrNoMoreLines) {
+			err = nil
+		}
+	}
+	return lines, pos, err
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (ci *ClaimingIterator) MustNext(count int) []string {
+	lines, err := ci.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (ci *ClaimingIterator) NextOne() (string, error) {
+	lines, err := ci.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (ci *ClaimingIterator) MustNextOne() string {
+	line, err := ci.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// Release gives up the current range, so another worker can claim it right away and continue where this one
+// stopped. Call it before shutting down.
+func (ci *ClaimingIterator) Release() error {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if ci.cur == nil {
+		return nil
+	}
+	r := ci.cur
+	ci.cur = nil
+	// an expired lease that keeps the position
+	if _, err := ci.persister.CompareAndSwap(ci.leaseKey(r.index), r.token, leasePos(r.token)); err != nil {
+		return fmt.Errorf("claim: name: %s -> %w", ci.Name(), err)
+	}
+	return nil
+}
+
+// Unwrap returns the wrapped iterator.
+func (ci *ClaimingIterator) Unwrap() PointerIterator {
+	return ci.PointerIterator
+}
@@ -0,0 +1,334 @@
+package lizt_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+// SyncPersister is an InMemoryPersister that's safe to share between workers.
+type SyncPersister struct {
+	mem *InMemoryPersister
+	mu  sync.Mutex
+}
+
+func NewSyncPersister() *SyncPersister {
+	return &SyncPersister{mem: NewInMemoryPersister()}
+}
+
+func (s *SyncPersister) Set(key string, value uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Set(key, value)
+}
+
+func (s *SyncPersister) Get(key string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Get(key)
+}
+
+func (s *SyncPersister) Increment(key string, delta uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Increment(key, delta)
+}
+
+func (s *SyncPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.CompareAndSwap(key, old, new)
+}
+
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%d", i)
+	}
+	return lines
+}
+
+// drain reads from ci until there are no more lines, counting every line it gets in seen.
+func drain(t *testing.T, ci *lizt.ClaimingIterator, count int, seen map[string]int, mu *sync.Mutex) {
+	for {
+		lines, err := ci.Next(count)
+		mu.Lock()
+		for _, line := range lines {
+			seen[line]++
+		}
+		mu.Unlock()
+		if errors.Is(err, lizt.ErrNoMoreLines) {
+			return
+		}
+		if err != nil {
+			t.Errorf("Next() error = %v", err)
+			return
+		}
+	}
+}
+
+func checkServedOnce(t *testing.T, lines []string, seen map[string]int) {
+	if len(seen) != len(lines) {
+		t.Errorf("expected %d lines, got %d", len(lines), len(seen))
+	}
+	for line, n := range seen {
+		if n != 1 {
+			t.Errorf("line %s served %d times", line, n)
+		}
+	}
+}
+
+func TestClaimingIterator_Workers(t *testing.T) {
+	lines := numberedLines(2500)
+	p := NewSyncPersister()
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		ci, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator("claims", lines, false),
+			Persister:   p,
+			RangeSize:   100,
+		})
+		if err != nil {
+			t.Fatalf("NewClaimingIterator() error = %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drain(t, ci, 7, seen, &mu)
+		}()
+	}
+	wg.Wait()
+
+	checkServedOnce(t, lines, seen)
+	if done, _ := p.Get("claims.claims.done"); done == 0 {
+		t.Errorf("expected the done watermark to advance")
+	}
+}
+
+func TestClaimingIterator_ReclaimsCrashedRange(t *testing.T) {
+	lines := numberedLines(300)
+	p := NewSyncPersister()
+	var mu sync.Mutex
+	seen := make(map[string]int)
+
+	crashed, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+		Timeout:     50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	for _, line := range crashed.MustNext(30) {
+		seen[line]++
+	}
+
+	worker, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+		Timeout:     50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	drain(t, worker, 25, seen, &mu)
+	if len(seen) != 230 {
+		t.Errorf("expected the leased range to be skipped, got %d lines", len(seen))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	drain(t, worker, 25, seen, &mu)
+	checkServedOnce(t, lines, seen)
+}
+
+func TestClaimingIterator_Release(t *testing.T) {
+	lines := numberedLines(100)
+	p := NewSyncPersister()
+	var mu sync.Mutex
+	seen := make(map[string]int)
+
+	first, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	for _, line := range first.MustNext(10) {
+		seen[line]++
+	}
+	if err := first.Release(); err != nil {
+		t.Errorf("Release() error = %v", err)
+	}
+
+	second, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	if line := second.MustNextOne(); line != "10" {
+		t.Errorf("expected %s, got %s", "10", line)
+	}
+	seen["10"]++
+	drain(t, second, 30, seen, &mu)
+	checkServedOnce(t, lines, seen)
+}
+
+func TestClaimingIterator_LostLease(t *testing.T) {
+	lines := numberedLines(200)
+	p := NewSyncPersister()
+	var mu sync.Mutex
+	seen := make(map[string]int)
+
+	slow, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+		Timeout:     30 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	for _, line := range slow.MustNext(10) {
+		seen[line]++
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	// the range of the slow worker expired and is taken over
+	fast, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	drain(t, fast, 50, seen, &mu)
+
+	// the slow worker drops its stale range instead of serving it again
+	if _, err := slow.Next(10); !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+	checkServedOnce(t, lines, seen)
+}
+
+// noSetPersister is a SyncPersister whose Set always fails, so only the atomic operations persist anything.
+type noSetPersister struct {
+	*SyncPersister
+}
+
+func (noSetPersister) Set(string, uint64) error {
+	return errors.New("set not supported")
+}
+
+func TestClaimingIterator_PositionIsPartOfTheLease(t *testing.T) {
+	lines := numberedLines(100)
+	p := noSetPersister{NewSyncPersister()}
+	var mu sync.Mutex
+	seen := make(map[string]int)
+
+	crashed, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+		Timeout:     20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	for _, line := range crashed.MustNext(30) {
+		seen[line]++
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	worker, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator("claims", lines, false),
+		Persister:   p,
+		RangeSize:   100,
+	})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+	if line := worker.MustNextOne(); line != "30" {
+		t.Errorf("expected %s, got %s", "30", line)
+	}
+	seen["30"]++
+	drain(t, worker, 25, seen, &mu)
+	checkServedOnce(t, lines, seen)
+}
+
+// setPointerCounter counts the calls to SetPointer of the iterator it wraps.
+type setPointerCounter struct {
+	lizt.PointerIterator
+	calls int
+}
+
+func (s *setPointerCounter) SetPointer(p uint64) {
+	s.calls++
+	s.PointerIterator.SetPointer(p)
+}
+
+func TestClaimingIterator_ShouldNotSeekInOrder(t *testing.T) {
+	lines := numberedLines(250)
+	iter := &setPointerCounter{PointerIterator: lizt.NewSliceIterator("claims", lines, false)}
+	ci, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{PointerIter: iter, Persister: NewSyncPersister(), RangeSize: 100})
+	if err != nil {
+		t.Fatalf("NewClaimingIterator() error = %v", err)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	drain(t, ci, 30, seen, &mu)
+	checkServedOnce(t, lines, seen)
+	if iter.calls != 0 {
+		t.Errorf("expected no SetPointer calls reading the ranges in order, got %d", iter.calls)
+	}
+}
+
+func TestClaimingIterator_Filter_ShouldStayInsideItsRanges(t *testing.T) {
+	lines := numberedLines(10)
+	p := NewSyncPersister()
+	workers := make([]*lizt.ClaimingIterator, 2)
+	for i := range workers {
+		ci, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+			PointerIter: lizt.NewFilterIterator(lizt.FilterIteratorConfig{
+				PointerIter: lizt.NewSliceIterator("claims", lines, false),
+				Fn:          func(s string) bool { return s != "1" && s != "3" },
+			}),
+			Persister: p,
+			RangeSize: 4,
+		})
+		if err != nil {
+			t.Fatalf("NewClaimingIterator() error = %v", err)
+		}
+		workers[i] = ci
+	}
+
+	// the filter reads past the end of the first range, into lines that belong to the next one
+	seen := make(map[string]int)
+	for _, ci := range append(workers, workers...) {
+		next, err := ci.Next(3)
+		if err != nil && !errors.Is(err, lizt.ErrNoMoreLines) {
+			t.Fatalf("Next() error = %v", err)
+		}
+		for _, line := range next {
+			seen[line]++
+		}
+	}
+
+	checkServedOnce(t, []string{"0", "2", "4", "5", "6", "7", "8", "9"}, seen)
+}
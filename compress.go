@@ -0,0 +1,166 @@
+package lizt
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var ErrCompressed = errors.New("compressed file")
+
+// Compression is the compression format of a file, detected from its magic bytes.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+	CompressionBzip2
+	CompressionXz
+)
+
+// compressionMagic lists the magic bytes of each format. A non-nil check also has to accept the bytes following the
+// magic, so plain text that happens to start with a short magic such as "BZh" isn't mistaken for compressed content.
+var compressionMagic = []struct {
+	magic       []byte
+	check       func(rest []byte) bool
+	compression Compression
+}{
+	{magic: []byte{0x1f, 0x8b}, compression: CompressionGzip},
+	{magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, compression: CompressionZstd},
+	{magic: []byte("BZh"), check: isBzip2BlockSize, compression: CompressionBzip2},
+	{magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, compression: CompressionXz},
+}
+
+// compressionSuffixes are stripped from filenames before they are turned into names.
+var compressionSuffixes = []string{".gz", ".zst", ".zstd", ".bz2", ".xz"}
+
+// String returns the name of the compression format.
+func (c Compression) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionBzip2:
+		return "bzip2"
+	case CompressionXz:
+		return "xz"
+	}
+	return "none"
+}
+
+// DetectCompression returns the compression format of a file by looking at its magic bytes.
+func DetectCompression(filename string) (Compression, error) {
+	file, err := OpenFile(filename)
+	if err != nil {
+		return CompressionNone, err
+	}
+	defer file.Close()
+
+	return detectCompression(file)
+}
+
+// detectCompression reads the magic bytes without moving the file offset.
+func detectCompression(file *os.File) (Compression, error) {
+	head := make([]byte, 6)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return CompressionNone, fmt.Errorf("file.ReadAt(): %s -> %w", file.Name(), err)
+	}
+
+	for _, m := range compressionMagic {
+		if !bytes.HasPrefix(head[:n], m.magic) {
+			continue
+		}
+		if m.check == nil || m.check(head[len(m.magic):n]) {
+			return m.compression, nil
+		}
+	}
+	return CompressionNone, nil
+}
+
+// isBzip2BlockSize reports whether the byte after "BZh" is a bzip2 block size, '1' to '9'.
+func isBzip2BlockSize(rest []byte) bool {
+	return len(rest) > 0 && rest[0] >= '1' && rest[0] <= '9'
+}
+
+// OpenReader opens a file and transparently decompresses gzip, zstd, bzip2 and xz content. Uncompressed files are
+// returned as the *os.File itself.
+func OpenReader(filename string) (io.ReadCloser, error) {
+	file, err := OpenFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := detectCompression(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if c == CompressionNone {
+		return file, nil
+	}
+
+	rc := &decompressReader{file: file}
+	switch c {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("gzip.NewReader(): %s -> %w", filename, err)
+		}
+		rc.Reader, rc.close = gz, func() { _ = gz.Close() }
+	case CompressionZstd:
+		zr, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("zstd.NewReader(): %s -> %w", filename, err)
+		}
+		rc.Reader, rc.close = zr, zr.Close
+	case CompressionBzip2:
+		rc.Reader = bzip2.NewReader(file)
+	case CompressionXz:
+		xr, err := xz.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("xz.NewReader(): %s -> %w", filename, err)
+		}
+		rc.Reader = xr
+	}
+	return rc, nil
+}
+
+// decompressReader reads decompressed content and closes both the decompressor and the file.
+type decompressReader struct {
+	io.Reader
+	close func()
+	file  *os.File
+}
+
+// Close closes the decompressor and the underlying file.
+func (d *decompressReader) Close() error {
+	if d.close != nil {
+		d.close()
+	}
+	return d.file.Close()
+}
+
+// trimCompressionSuffix removes a known compression suffix from the filename.
+func trimCompressionSuffix(filename string) string {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return strings.TrimSuffix(filename, suffix)
+		}
+	}
+	return filename
+}
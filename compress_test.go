@@ -0,0 +1,155 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+var compressedFiles = map[string]lizt.Compression{
+	"test/compressed/gzip.txt.gz":   lizt.CompressionGzip,
+	"test/compressed/zstd.txt.zst":  lizt.CompressionZstd,
+	"test/compressed/bzip2.txt.bz2": lizt.CompressionBzip2,
+	"test/compressed/xz.txt.xz":     lizt.CompressionXz,
+}
+
+var tenLines = []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+
+func TestDetectCompression(t *testing.T) {
+	for filename, want := range compressedFiles {
+		got, err := lizt.DetectCompression(filename)
+		if err != nil {
+			t.Errorf("DetectCompression(%s) error = %v", filename, err)
+		}
+		if got != want {
+			t.Errorf("DetectCompression(%s) = %s, want %s", filename, got, want)
+		}
+	}
+
+	got, err := lizt.DetectCompression(filenameTen)
+	if err != nil || got != lizt.CompressionNone {
+		t.Errorf("DetectCompression(%s) = %s, %v, want none", filenameTen, got, err)
+	}
+}
+
+func TestDetectCompression_ShouldNotMistakeTextForBzip2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bzh.txt")
+	if err := os.WriteFile(path, []byte("BZhello\nBZh\nworld\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	got, err := lizt.DetectCompression(path)
+	if err != nil || got != lizt.CompressionNone {
+		t.Errorf("DetectCompression(%s) = %s, %v, want none", path, got, err)
+	}
+
+	lines, err := lizt.ReadFromFile(path)
+	if err != nil {
+		t.Errorf("ReadFromFile(%s) error = %v", path, err)
+	}
+	expected := []string{"BZhello", "BZh", "world"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("ReadFromFile(%s) = %v, want %v", path, lines, expected)
+	}
+}
+
+func TestReadFromFile_Compressed(t *testing.T) {
+	for filename := range compressedFiles {
+		count, err := lizt.FileLineCount(filename)
+		if err != nil {
+			t.Errorf("FileLineCount(%s) error = %v", filename, err)
+		}
+		if count != 10 {
+			t.Errorf("FileLineCount(%s) = %d, want %d", filename, count, 10)
+		}
+
+		lines, err := lizt.ReadFromFile(filename)
+		if err != nil {
+			t.Errorf("ReadFromFile(%s) error = %v", filename, err)
+		}
+		if !reflect.DeepEqual(lines, tenLines) {
+			t.Errorf("ReadFromFile(%s) = %v, want %v", filename, lines, tenLines)
+		}
+	}
+}
+
+func TestStreamIterator_Compressed_RoundRobinAndSetPointer(t *testing.T) {
+	for filename := range compressedFiles {
+		fs, err := lizt.NewStreamIterator(filename, true)
+		if err != nil {
+			t.Fatalf("NewStreamIterator(%s) error = %v", filename, err)
+		}
+
+		next, err := fs.Next(12)
+		if err != nil {
+			t.Errorf("Next(%s) error = %v", filename, err)
+		}
+		expected := append(append([]string{}, tenLines...), "a", "b")
+		if !reflect.DeepEqual(next, expected) {
+			t.Errorf("Next(%s) = %v, want %v", filename, next, expected)
+		}
+
+		fs.SetPointer(6)
+		next, err = fs.Next(2)
+		if err != nil {
+			t.Errorf("Next(%s) error = %v", filename, err)
+		}
+		if !reflect.DeepEqual(next, []string{"g", "h"}) {
+			t.Errorf("Next(%s) = %v, want %v", filename, next, []string{"g", "h"})
+		}
+		_ = fs.Close()
+	}
+}
+
+func TestIndexedStreamIterator_Compressed_SetPointer(t *testing.T) {
+	stride := lizt.IndexStride
+	lizt.IndexStride = 3
+	defer func() { lizt.IndexStride = stride }()
+
+	path := copyToTemp(t, "test/compressed/zstd.txt.zst")
+	fs, err := lizt.NewIndexedStreamIterator(path, false)
+	if err != nil {
+		t.Fatalf("NewIndexedStreamIterator() error = %v", err)
+	}
+	defer fs.Close()
+
+	fs.SetPointer(7)
+	next, err := fs.Next(5)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"h", "i", "j"}) {
+		t.Errorf("Next() = %v, want %v", next, []string{"h", "i", "j"})
+	}
+}
+
+func TestManager_AddDirIter_Compressed(t *testing.T) {
+	mgr := lizt.NewManager()
+	if err := mgr.AddDirIter("test/compressed/", false); err != nil {
+		t.Fatalf("AddDirIter() error = %v", err)
+	}
+
+	expected := []string{"bzip2", "gzip", "xz", "zstd"}
+	if !reflect.DeepEqual(mgr.List(), expected) {
+		t.Errorf("List() = %v, want %v", mgr.List(), expected)
+	}
+
+	next, err := mgr.MustGet("zstd").Next(3)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"a", "b", "c"}) {
+		t.Errorf("Next() = %v, want %v", next, []string{"a", "b", "c"})
+	}
+}
+
+func TestMmapIterator_Compressed(t *testing.T) {
+	_, err := lizt.NewMmapIterator("test/compressed/gzip.txt.gz", false)
+	if !errors.Is(err, lizt.ErrCompressed) {
+		t.Errorf("wanted ErrCompressed, got error = %v", err)
+	}
+}
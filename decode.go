@@ -0,0 +1,220 @@
+package lizt
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrTooFewFields = errors.New("too few fields")
+	ErrNoSuchField  = errors.New("no such field")
+)
+
+// fieldMapper assigns columns to the fields of a struct. A column maps to the field tagged `lizt:"<column>"`, or
+// else to the field with the same name, ignoring case. A column named "-" or "" is ignored.
+type fieldMapper[T any] struct {
+	columns []string
+	fields  [][]int
+}
+
+func newFieldMapper[T any](columns []string) (*fieldMapper[T], error) {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("decode: %v is not a struct", typ)
+	}
+
+	fm := &fieldMapper[T]{columns: columns, fields: make([][]int, len(columns))}
+	for i, column := range columns {
+		if column == "" || column == "-" {
+			continue
+		}
+
+		field, ok := findField(typ, column)
+		if !ok {
+			return nil, fmt.Errorf("decode: column %s: %v -> %w", column, typ, ErrNoSuchField)
+		}
+		if err := checkFieldKind(field.Type); err != nil {
+			return nil, fmt.Errorf("decode: column %s: field %s -> %w", column, field.Name, err)
+		}
+		fm.fields[i] = field.Index
+	}
+	return fm, nil
+}
+
+// findField returns the field for a column, preferring a tag over the field name.
+func findField(typ reflect.Type, column string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.IsExported() && field.Tag.Get("lizt") == column {
+			return field, true
+		}
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.IsExported() && field.Tag.Get("lizt") == "" && strings.EqualFold(field.Name, column) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func checkFieldKind(typ reflect.Type) error {
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	}
+	return fmt.Errorf("unsupported kind %s", typ.Kind())
+}
+
+// decode assigns the values to a new record.
+func (fm *fieldMapper[T]) decode(values []string) (T, error) {
+	var record T
+	if len(values) < len(fm.columns) {
+		return record, fmt.Errorf("%d of %d -> %w", len(values), len(fm.columns), ErrTooFewFields)
+	}
+
+	v := reflect.ValueOf(&record).Elem()
+	for i, index := range fm.fields {
+		if index == nil {
+			continue
+		}
+		if err := setField(v.FieldByIndex(index), values[i]); err != nil {
+			return record, fmt.Errorf("column %s: %w", fm.columns[i], err)
+		}
+	}
+	return record, nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	}
+	return nil
+}
+
+// CSVDecoder decodes comma (or e.g. tab) separated lines into structs. Quoted fields are supported, but a record
+// can't span lines.
+type CSVDecoder[T any] struct {
+	mapper     *fieldMapper[T]
+	comma      rune
+	skipHeader bool
+}
+
+// CSVDecoderConfig is the config for a CSV decoder. Columns maps the columns, in order, to struct fields. Comma
+// defaults to ','. With SkipHeader, a line whose fields equal Columns is skipped.
+type CSVDecoderConfig struct {
+	Columns    []string
+	Comma      rune
+	SkipHeader bool
+}
+
+// NewCSVDecoder returns a new CSV decoder for records of type T, which must be a struct.
+func NewCSVDecoder[T any](cfg CSVDecoderConfig) (*CSVDecoder[T], error) {
+	mapper, err := newFieldMapper[T](cfg.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	comma := cfg.Comma
+	if comma == 0 {
+		comma = ','
+	}
+	return &CSVDecoder[T]{mapper: mapper, comma: comma, skipHeader: cfg.SkipHeader}, nil
+}
+
+// NewTSVDecoder returns a new CSV decoder for tab separated lines.
+func NewTSVDecoder[T any](columns []string, skipHeader bool) (*CSVDecoder[T], error) {
+	return NewCSVDecoder[T](CSVDecoderConfig{Columns: columns, Comma: '\t', SkipHeader: skipHeader})
+}
+
+// Decode decodes a line.
+func (d *CSVDecoder[T]) Decode(line string) (T, error) {
+	r := csv.NewReader(strings.NewReader(line))
+	r.Comma = d.comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	values, err := r.Read()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if d.skipHeader && reflect.DeepEqual(values, d.mapper.columns) {
+		var zero T
+		return zero, ErrSkipRecord
+	}
+	return d.mapper.decode(values)
+}
+
+// DelimitedDecoder decodes lines like "host:port:user:pass" into structs. The last column takes the rest of the
+// line, so it may contain the separator, e.g. a password with a colon.
+type DelimitedDecoder[T any] struct {
+	mapper *fieldMapper[T]
+	sep    string
+}
+
+// NewDelimitedDecoder returns a new delimited decoder for records of type T, which must be a struct. The columns
+// map the fields, in order, to struct fields.
+func NewDelimitedDecoder[T any](sep string, columns ...string) (*DelimitedDecoder[T], error) {
+	mapper, err := newFieldMapper[T](columns)
+	if err != nil {
+		return nil, err
+	}
+	return &DelimitedDecoder[T]{mapper: mapper, sep: sep}, nil
+}
+
+// Decode decodes a line.
+func (d *DelimitedDecoder[T]) Decode(line string) (T, error) {
+	return d.mapper.decode(strings.SplitN(line, d.sep, len(d.mapper.columns)))
+}
+
+// JSONLDecoder decodes JSON Lines, one JSON value per line. Blank lines are skipped.
+type JSONLDecoder[T any] struct{}
+
+// NewJSONLDecoder returns a new JSON Lines decoder.
+func NewJSONLDecoder[T any]() JSONLDecoder[T] {
+	return JSONLDecoder[T]{}
+}
+
+// Decode decodes a line.
+func (JSONLDecoder[T]) Decode(line string) (T, error) {
+	var record T
+	if strings.TrimSpace(line) == "" {
+		return record, ErrSkipRecord
+	}
+	err := json.Unmarshal([]byte(line), &record)
+	return record, err
+}
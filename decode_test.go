@@ -0,0 +1,107 @@
+package lizt_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+type proxy struct {
+	Host     string
+	Port     int
+	User     string `lizt:"username"`
+	Password string `lizt:"password"`
+}
+
+func TestDelimitedDecoder(t *testing.T) {
+	dec, err := lizt.NewDelimitedDecoder[proxy](":", "host", "port", "username", "password")
+	if err != nil {
+		t.Fatalf("NewDelimitedDecoder() error = %v", err)
+	}
+
+	lines := []string{"1.2.3.4:8080:bob:secret", "5.6.7.8:3128:alice:pa:ss", "9.9.9.9:nope:x:y", "1.1.1.1:80"}
+	ti := lizt.NewTypedIterator[proxy](lizt.NewSliceIterator(nameNumbers, lines, false), dec)
+
+	next, err := ti.Next(4)
+	expected := []proxy{
+		{Host: "1.2.3.4", Port: 8080, User: "bob", Password: "secret"},
+		{Host: "5.6.7.8", Port: 3128, User: "alice", Password: "pa:ss"},
+	}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+
+	var decodeErr *lizt.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Line != 3 {
+		t.Errorf("wanted a DecodeError on line %d, got error = %v", 3, err)
+	}
+
+	_, err = dec.Decode("1.1.1.1:80")
+	if !errors.Is(err, lizt.ErrTooFewFields) {
+		t.Errorf("wanted ErrTooFewFields, got error = %v", err)
+	}
+}
+
+func TestCSVDecoder(t *testing.T) {
+	dec, err := lizt.NewCSVDecoder[proxy](lizt.CSVDecoderConfig{
+		Columns:    []string{"username", "-", "host", "port"},
+		SkipHeader: true,
+	})
+	if err != nil {
+		t.Fatalf("NewCSVDecoder() error = %v", err)
+	}
+
+	lines := []string{"username,-,host,port", `"smith, bob",ignored,1.2.3.4,8080`, "alice,,5.6.7.8,3128"}
+	next, err := lizt.NewTypedIterator[proxy](lizt.NewSliceIterator(nameNumbers, lines, false), dec).Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	expected := []proxy{
+		{Host: "1.2.3.4", Port: 8080, User: "smith, bob"},
+		{Host: "5.6.7.8", Port: 3128, User: "alice"},
+	}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+
+	tsv, err := lizt.NewTSVDecoder[proxy]([]string{"host", "port"}, false)
+	if err != nil {
+		t.Fatalf("NewTSVDecoder() error = %v", err)
+	}
+	record, err := tsv.Decode("1.2.3.4\t8080")
+	if err != nil {
+		t.Errorf("Decode() error = %v", err)
+	}
+	if record != (proxy{Host: "1.2.3.4", Port: 8080}) {
+		t.Errorf("expected %v, got %v", proxy{Host: "1.2.3.4", Port: 8080}, record)
+	}
+
+	if _, err = lizt.NewCSVDecoder[proxy](lizt.CSVDecoderConfig{Columns: []string{"nope"}}); !errors.Is(err, lizt.ErrNoSuchField) {
+		t.Errorf("wanted ErrNoSuchField, got error = %v", err)
+	}
+	if _, err = lizt.NewCSVDecoder[string](lizt.CSVDecoderConfig{Columns: []string{"host"}}); err == nil {
+		t.Errorf("expected an error for a non-struct record")
+	}
+}
+
+func TestJSONLDecoder(t *testing.T) {
+	type job struct {
+		ID   int      `json:"id"`
+		Tags []string `json:"tags"`
+	}
+
+	lines := []string{`{"id": 1, "tags": ["a"]}`, ``, `{"id": 2}`, `{"id": "three"}`}
+	ti := lizt.NewTypedIterator[job](lizt.NewSliceIterator(nameNumbers, lines, false), lizt.NewJSONLDecoder[job]())
+
+	next, err := ti.Next(3)
+	if !reflect.DeepEqual(next, []job{{ID: 1, Tags: []string{"a"}}, {ID: 2}}) {
+		t.Errorf("expected %v, got %v", []job{{ID: 1, Tags: []string{"a"}}, {ID: 2}}, next)
+	}
+
+	var decodeErr *lizt.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Line != 4 {
+		t.Errorf("wanted a DecodeError on line %d, got error = %v", 4, err)
+	}
+}
@@ -0,0 +1,262 @@
+package lizt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrInvalidSeenSet = errors.New("invalid seen set")
+
+// DefaultDedupeFalsePositiveRate is the false-positive rate used when DedupingIteratorConfig.FalsePositiveRate is zero.
+var DefaultDedupeFalsePositiveRate = 0.001
+
+// seenMagic identifies (and versions) a saved seen-set.
+var seenMagic = [8]byte{'L', 'I', 'Z', 'T', 'S', 'E', 'E', 'N'}
+
+// DedupeMode decides how a DedupingIterator remembers the lines it has emitted.
+type DedupeMode int
+
+const (
+	// DedupeExact keeps every emitted line in a hash set. It never drops a unique line, but memory grows with the
+	// number of unique lines.
+	DedupeExact DedupeMode = iota
+	// DedupeApprox keeps a bloom filter of emitted lines. Memory is fixed, but unique lines are dropped at roughly
+	// the configured false-positive rate.
+	DedupeApprox
+)
+
+// DedupingIterator is an iterator that never emits the same line twice.
+type DedupingIterator struct {
+	PointerIterator
+	mode  DedupeMode
+	exact map[string]struct{}
+	bloom *BloomFilter
+	mu    sync.Mutex
+}
+
+// DedupingIteratorConfig is the config for a deduping iterator. ExpectedLines and FalsePositiveRate size the bloom
+// filter of DedupeApprox; ExpectedLines defaults to the length of the wrapped iterator.
+type DedupingIteratorConfig struct {
+	PointerIter       PointerIterator
+	Mode              DedupeMode
+	ExpectedLines     uint64
+	FalsePositiveRate float64
+}
+
+// NewDedupingIterator returns a new deduping iterator with an empty seen-set.
+func NewDedupingIterator(cfg DedupingIteratorConfig) *DedupingIterator {
+	di := &DedupingIterator{
+		PointerIterator: cfg.PointerIter,
+		mode:            cfg.Mode,
+	}
+
+	switch cfg.Mode {
+	case DedupeApprox:
+		n := cfg.ExpectedLines
+		if n == 0 {
+			n = uint64(cfg.PointerIter.Len())
+		}
+		rate := cfg.FalsePositiveRate
+		if rate == 0 {
+			rate = DefaultDedupeFalsePositiveRate
+		}
+		di.bloom = NewBloomFilter(n, rate)
+	default:
+		di.exact = make(map[string]struct{})
+	}
+	return di
+}
+
+// Next returns the next unseen lines, of a given count, from the iterator.
+func (di *DedupingIterator) Next(count int) ([]string, error) {
+	return di.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next unseen lines, of a given count, from the iterator. It stops early if ctx is done.
+// A round-robin iterator that has nothing new to offer for a whole cycle returns ErrNoMoreLines instead of spinning.
+func (di *DedupingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	return nextKept(ctx, di.PointerIterator, "dedupe", count, true, func(line string) bool { return !di.seen(line) })
+}
+
+// seen records a line and returns true if it was already emitted.
+func (di *DedupingIterator) seen(line string) bool {
+	if di.bloom != nil {
+		return di.bloom.TestAndAdd(line)
+	}
+	if _, ok := di.exact[line]; ok {
+		return true
+	}
+	di.exact[line] = struct{}{}
+	return false
+}
+
+// Seen returns the number of unique lines emitted so far.
+func (di *DedupingIterator) Seen() int {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	if di.bloom != nil {
+		return di.bloom.Len()
+	}
+	return len(di.exact)
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (di *DedupingIterator) MustNext(count int) []string {
+	lines, err := di.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (di *DedupingIterator) NextOne() (string, error) {
+	lines, err := di.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (di *DedupingIterator) MustNextOne() string {
+	line, err := di.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// advance moves the wrapped iterator forward to line p.
+func (di *DedupingIterator) advance(p uint64) {
+	advanceTo(di.PointerIterator, p)
+}
+
+// Unwrap returns the wrapped iterator.
+func (di *DedupingIterator) Unwrap() PointerIterator {
+	return di.PointerIterator
+}
+
+// SaveSeen writes the seen-set together with the current pointer to w, so that both can be restored as one
+// consistent snapshot.
+func (di *DedupingIterator) SaveSeen(w io.Writer) error {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	buf.Write(seenMagic[:])
+	_ = binary.Write(buf, binary.LittleEndian, uint64(di.mode))
+	_ = binary.Write(buf, binary.LittleEndian, di.PointerIterator.Pointer())
+
+	if di.bloom != nil {
+		if _, err := di.bloom.WriteTo(buf); err != nil {
+			return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+		}
+	} else {
+		_ = binary.Write(buf, binary.LittleEndian, uint64(len(di.exact)))
+		var size [binary.MaxVarintLen64]byte
+		for line := range di.exact {
+			buf.Write(size[:binary.PutUvarint(size[:], uint64(len(line)))])
+			buf.WriteString(line)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+	}
+	return nil
+}
+
+// LoadSeen replaces the seen-set with one written by SaveSeen and moves the pointer back to where it was when the
+// snapshot was taken. Lines emitted after the snapshot are emitted again, but never a line the snapshot has seen.
+func (di *DedupingIterator) LoadSeen(r io.Reader) error {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	// the sizes in the snapshot are checked against its length, so a corrupt one can't make us allocate more than that
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+	}
+	buf := bytes.NewReader(data)
+
+	var header [8 + 8*2]byte
+	if _, err := io.ReadFull(buf, header[:]); err != nil {
+		return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+	}
+	if !bytes.Equal(header[:8], seenMagic[:]) {
+		return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), ErrInvalidSeenSet)
+	}
+	if mode := DedupeMode(binary.LittleEndian.Uint64(header[8:])); mode != di.mode {
+		return fmt.Errorf("dedupe: name: %s: mode %d, want %d -> %w", di.Name(), mode, di.mode, ErrInvalidSeenSet)
+	}
+	pointer := binary.LittleEndian.Uint64(header[16:])
+
+	if di.mode == DedupeApprox {
+		bloom := &BloomFilter{}
+		if _, err := bloom.ReadFrom(buf); err != nil {
+			return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+		}
+		di.bloom = bloom
+	} else {
+		var count uint64
+		if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
+			return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+		}
+		// every line takes at least the byte of its size
+		if count > uint64(buf.Len()) {
+			return fmt.Errorf("dedupe: name: %s: %d lines -> %w", di.Name(), count, ErrInvalidSeenSet)
+		}
+
+		exact := make(map[string]struct{}, count)
+		for i := uint64(0); i < count; i++ {
+			size, err := binary.ReadUvarint(buf)
+			if err != nil {
+				return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+			}
+			if size > uint64(buf.Len()) {
+				return fmt.Errorf("dedupe: name: %s: line of %d bytes -> %w", di.Name(), size, ErrInvalidSeenSet)
+			}
+			line := make([]byte, size)
+			if _, err = io.ReadFull(buf, line); err != nil {
+				return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+			}
+			exact[string(line)] = struct{}{}
+		}
+		di.exact = exact
+	}
+
+	di.PointerIterator.SetPointer(pointer)
+	return nil
+}
+
+// SaveSeenFile atomically writes the seen-set and the pointer to a file.
+func (di *DedupingIterator) SaveSeenFile(filename string) error {
+	var buf bytes.Buffer
+	if err := di.SaveSeen(&buf); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, buf.Bytes())
+}
+
+// LoadSeenFile restores the seen-set and the pointer from a file written by SaveSeenFile.
+func (di *DedupingIterator) LoadSeenFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("dedupe: name: %s -> %w", di.Name(), err)
+	}
+	defer file.Close()
+
+	return di.LoadSeen(file)
+}
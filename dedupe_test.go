@@ -0,0 +1,153 @@
+package lizt_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+var duplicated = []string{"a", "b", "a", "c", "b", "d", "a", "e"}
+
+func TestDedupingIterator_Next(t *testing.T) {
+	for _, mode := range []lizt.DedupeMode{lizt.DedupeExact, lizt.DedupeApprox} {
+		di := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, duplicated, false),
+			Mode:        mode,
+		})
+
+		next, err := di.Next(4)
+		if err != nil {
+			t.Errorf("mode %d: Next() error = %v", mode, err)
+		}
+		if !reflect.DeepEqual(next, []string{"a", "b", "c", "d"}) {
+			t.Errorf("mode %d: expected %v, got %v", mode, []string{"a", "b", "c", "d"}, next)
+		}
+
+		// asking for more than is left returns what is left.
+		next, err = di.Next(4)
+		if err != nil {
+			t.Errorf("mode %d: Next() error = %v", mode, err)
+		}
+		if !reflect.DeepEqual(next, []string{"e"}) {
+			t.Errorf("mode %d: expected %v, got %v", mode, []string{"e"}, next)
+		}
+
+		_, err = di.Next(1)
+		if !errors.Is(err, lizt.ErrNoMoreLines) {
+			t.Errorf("mode %d: wanted ErrNoMoreLines, got error = %v", mode, err)
+		}
+	}
+}
+
+func TestDedupingIterator_Next_RoundRobin_ShouldNotSpin(t *testing.T) {
+	slice := lizt.B().SliceNamedRR(nameNumbers, duplicated).Dedupe().MustBuild()
+
+	next, err := slice.Next(10)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b", "c", "d", "e"}, next)
+	}
+
+	_, err = slice.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestDedupingIterator_SaveSeen_LoadSeen(t *testing.T) {
+	for _, mode := range []lizt.DedupeMode{lizt.DedupeExact, lizt.DedupeApprox} {
+		path := filepath.Join(t.TempDir(), "seen.bin")
+
+		first := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, duplicated, false),
+			Mode:        mode,
+		})
+		first.MustNext(3)
+		if err := first.SaveSeenFile(path); err != nil {
+			t.Fatalf("mode %d: SaveSeenFile() error = %v", mode, err)
+		}
+
+		second := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, duplicated, false),
+			Mode:        mode,
+		})
+		if err := second.LoadSeenFile(path); err != nil {
+			t.Fatalf("mode %d: LoadSeenFile() error = %v", mode, err)
+		}
+		if second.Pointer() != first.Pointer() {
+			t.Errorf("mode %d: expected pointer %d, got %d", mode, first.Pointer(), second.Pointer())
+		}
+		if second.Seen() != 3 {
+			t.Errorf("mode %d: expected %d seen, got %d", mode, 3, second.Seen())
+		}
+
+		next, err := second.Next(10)
+		if err != nil {
+			t.Errorf("mode %d: Next() error = %v", mode, err)
+		}
+		if !reflect.DeepEqual(next, []string{"d", "e"}) {
+			t.Errorf("mode %d: expected %v, got %v", mode, []string{"d", "e"}, next)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "seen.bin")
+	exact := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{PointerIter: lizt.NewSliceIterator(nameNumbers, duplicated, false)})
+	if err := exact.SaveSeenFile(path); err != nil {
+		t.Fatalf("SaveSeenFile() error = %v", err)
+	}
+	approx := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, duplicated, false),
+		Mode:        lizt.DedupeApprox,
+	})
+	if err := approx.LoadSeenFile(path); !errors.Is(err, lizt.ErrInvalidSeenSet) {
+		t.Errorf("wanted ErrInvalidSeenSet, got error = %v", err)
+	}
+}
+
+func TestDedupingIterator_Persistent(t *testing.T) {
+	mem := NewInMemoryPersister()
+	p := lizt.B().SliceNamed(nameNumbers, duplicated, false).Dedupe().PersistTo(mem).MustBuild()
+
+	next, err := p.Next(3)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"a", "b", "c"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b", "c"}, next)
+	}
+	if mem.pointers[nameNumbers] != 4 {
+		t.Errorf("expected %d, got %d", 4, mem.pointers[nameNumbers])
+	}
+}
+
+func TestDedupingIterator_LoadSeen_ShouldRejectOversizedCounts(t *testing.T) {
+	di := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"a"}, false)})
+	di.MustNext(1)
+
+	var buf bytes.Buffer
+	if err := di.SaveSeen(&buf); err != nil {
+		t.Fatalf("SaveSeen() error = %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	// the line count follows the 24 byte header, the size of the line follows the count
+	count := append([]byte{}, snapshot...)
+	binary.LittleEndian.PutUint64(count[24:], math.MaxUint64)
+	size := append([]byte{}, snapshot...)
+	size[32] = 0x7f
+
+	for name, data := range map[string][]byte{"count": count, "size": size} {
+		loaded := lizt.NewDedupingIterator(lizt.DedupingIteratorConfig{PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"a"}, false)})
+		if err := loaded.LoadSeen(bytes.NewReader(data)); !errors.Is(err, lizt.ErrInvalidSeenSet) {
+			t.Errorf("%s: wanted ErrInvalidSeenSet, got error = %v", name, err)
+		}
+	}
+}
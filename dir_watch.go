@@ -0,0 +1,279 @@
+package lizt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDirWatchInterval is the polling interval used when DirWatchOptions.Interval is zero.
+var DefaultDirWatchInterval = 5 * time.Second
+
+// DirEventType is the kind of change a DirWatcher made to the manager.
+type DirEventType int
+
+const (
+	DirAdded DirEventType = iota
+	DirReloaded
+	DirRemoved
+)
+
+func (t DirEventType) String() string {
+	switch t {
+	case DirAdded:
+		return "added"
+	case DirReloaded:
+		return "reloaded"
+	case DirRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("DirEventType(%d)", int(t))
+}
+
+// DirEvent reports a list a DirWatcher added, reloaded or removed. Old is the iterator that a reload replaced or a
+// removal took out of the manager. The watcher doesn't close it, since workers may still be reading it, so close it
+// once they're done.
+type DirEvent struct {
+	Type DirEventType
+	Name string
+	Path string
+	Old  Iterator
+}
+
+// DirWatchOptions are the options of Manager.WatchDir. OnEvent is called for every list that's added, reloaded or
+// removed, and OnError when a file can't be read or its name is taken by another iterator.
+type DirWatchOptions struct {
+	Interval   time.Duration
+	RoundRobin bool
+	OnEvent    func(DirEvent)
+	OnError    func(error)
+}
+
+// DirWatcher polls a directory and keeps the lists of a manager in step with it: new files are added, modified files
+// are reloaded, keeping their pointers where valid, and deleted files are removed. Files become iterators
+// the way SmartAddDirIter makes them, so large files are streamed.
+type DirWatcher struct {
+	manager  *Manager
+	dir      string
+	opts     DirWatchOptions
+	stats    map[string]os.FileInfo
+	skipped  map[string]struct{}
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+// WatchDir adds the files of dir that aren't in the manager yet and starts polling dir for changes. Files that were
+// already added from dir, e.g. by SmartAddDirIter, are watched as they are. Call Stop on the watcher to stop polling.
+func (m *Manager) WatchDir(dir string, opts DirWatchOptions) (*DirWatcher, error) {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("manager: watch: %s -> %w", dir, err)
+	}
+
+	dw := &DirWatcher{
+		manager:  m,
+		dir:      dir,
+		opts:     opts,
+		stats:    make(map[string]os.FileInfo),
+		skipped:  make(map[string]struct{}),
+		interval: opts.Interval,
+	}
+	if dw.interval <= 0 {
+		dw.interval = DefaultDirWatchInterval
+	}
+
+	dw.notify(dw.Check())
+
+	dw.stop = make(chan struct{})
+	dw.done = make(chan struct{})
+	go dw.poll(dw.stop, dw.done)
+	return dw, nil
+}
+
+// Check syncs the manager with the directory once and returns what it changed. A file that fails is skipped and
+// retried on the next check; the first such error is returned. A file whose name is taken by another iterator is
+// reported once, and added once the name is free.
+func (dw *DirWatcher) Check() ([]DirEvent, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	files, err := ReadDir(dw.dir)
+	if err != nil {
+		return nil, fmt.Errorf("manager: watch: %s -> %w", dw.dir, err)
+	}
+
+	var (
+		events   []DirEvent
+		firstErr error
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	seen := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		seen[f] = struct{}{}
+		name := makeNameFromFilename(f)
+
+		info, err := os.Stat(f)
+		if err != nil {
+			// deleted since ReadDir, the next check removes it
+			continue
+		}
+
+		last, watched := dw.stats[f]
+		switch {
+		case !watched && dw.manager.hasSource(name, f):
+			dw.stats[f] = info
+		case !watched:
+			_, reported := dw.skipped[f]
+			if reported && dw.manager.has(name) {
+				continue
+			}
+			if err = dw.add(f, name); err != nil {
+				if errors.Is(err, ErrKeyExists) {
+					dw.skipped[f] = struct{}{}
+				}
+				if !reported {
+					fail(err)
+				}
+				continue
+			}
+			delete(dw.skipped, f)
+			dw.stats[f] = info
+			events = append(events, DirEvent{Type: DirAdded, Name: name, Path: f})
+		case last.Size() != info.Size() || !last.ModTime().Equal(info.ModTime()):
+			old, err := dw.manager.Reload(name)
+			if err != nil {
+				fail(fmt.Errorf("manager: watch: %s -> %w", f, err))
+				continue
+			}
+			dw.stats[f] = info
+			events = append(events, DirEvent{Type: DirReloaded, Name: name, Path: f, Old: old})
+		}
+	}
+
+	for f := range dw.skipped {
+		if _, ok := seen[f]; !ok {
+			delete(dw.skipped, f)
+		}
+	}
+
+	var removed []string
+	for f := range dw.stats {
+		if _, ok := seen[f]; !ok {
+			removed = append(removed, f)
+		}
+	}
+	sort.Strings(removed)
+	for _, f := range removed {
+		delete(dw.stats, f)
+
+		name := makeNameFromFilename(f)
+		if old := dw.manager.removeSource(name, f); old != nil {
+			events = append(events, DirEvent{Type: DirRemoved, Name: name, Path: f, Old: old})
+		}
+	}
+
+	return events, firstErr
+}
+
+// add creates the iterator of a new file and adds it to the manager.
+func (dw *DirWatcher) add(f, name string) error {
+	src := source{path: f, roundRobin: dw.opts.RoundRobin, smart: true}
+	iter, err := src.open()
+	if err != nil {
+		return fmt.Errorf("manager: watch: %s -> %w", f, err)
+	}
+
+	dw.manager.mu.Lock()
+	err = dw.manager.add([]Iterator{iter}, []source{src})
+	dw.manager.mu.Unlock()
+	if err != nil {
+		closeIter(iter)
+		return fmt.Errorf("manager: watch: %s -> %w", f, err)
+	}
+	return nil
+}
+
+func (dw *DirWatcher) notify(events []DirEvent, err error) {
+	if err != nil && dw.opts.OnError != nil {
+		dw.opts.OnError(err)
+	}
+	if dw.opts.OnEvent != nil {
+		for _, event := range events {
+			dw.opts.OnEvent(event)
+		}
+	}
+}
+
+func (dw *DirWatcher) poll(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(dw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			dw.notify(dw.Check())
+		}
+	}
+}
+
+// Stop stops polling and waits for a running check to finish. The lists stay in the manager.
+func (dw *DirWatcher) Stop() {
+	dw.mu.Lock()
+	stop, done := dw.stop, dw.done
+	dw.stop, dw.done = nil, nil
+	dw.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// has reports whether there's an iterator called name.
+func (m *Manager) has(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.files[name] != nil
+}
+
+// hasSource reports whether the iterator called name was created from the file at path.
+func (m *Manager) hasSource(name, path string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	src, ok := m.sources[name]
+	return ok && src.path == path
+}
+
+// removeSource removes the iterator called name if it was created from the file at path, and returns it.
+func (m *Manager) removeSource(name, path string) Iterator {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if src, ok := m.sources[name]; !ok || src.path != path {
+		return nil
+	}
+	iter := m.files[name]
+	delete(m.files, name)
+	delete(m.sources, name)
+	return iter
+}
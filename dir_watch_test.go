@@ -0,0 +1,222 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestManager_WatchDir_Check(t *testing.T) {
+	dir := t.TempDir()
+	letters, numbers := filepath.Join(dir, "letters.txt"), filepath.Join(dir, "numbers.txt")
+	start := time.Now().Add(-time.Hour)
+	writeBlacklist(t, letters, []string{"a", "b", "c"}, start)
+
+	var events []lizt.DirEvent
+	mgr := lizt.NewManager()
+	dw, err := mgr.WatchDir(dir, lizt.DirWatchOptions{
+		Interval: time.Hour,
+		OnEvent:  func(e lizt.DirEvent) { events = append(events, e) },
+	})
+	if err != nil {
+		t.Fatalf("WatchDir() error = %v", err)
+	}
+	defer dw.Stop()
+
+	expected := []lizt.DirEvent{{Type: lizt.DirAdded, Name: "letters", Path: letters}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+	lettersIter := mgr.MustGet("letters")
+	lettersIter.MustNext(2)
+
+	writeBlacklist(t, numbers, []string{"1"}, start)
+	writeBlacklist(t, letters, []string{"a", "b", "c", "d"}, start.Add(time.Minute))
+	changes, err := dw.Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	expected = []lizt.DirEvent{
+		{Type: lizt.DirReloaded, Name: "letters", Path: letters, Old: lettersIter},
+		{Type: lizt.DirAdded, Name: "numbers", Path: numbers},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+	if next := mgr.MustGet("letters").MustNext(2); !reflect.DeepEqual(next, []string{"c", "d"}) {
+		t.Errorf("expected the pointer to be kept, got %v", next)
+	}
+
+	numbersIter := mgr.MustGet("numbers")
+	if err = os.Remove(numbers); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	changes, err = dw.Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	expected = []lizt.DirEvent{{Type: lizt.DirRemoved, Name: "numbers", Path: numbers, Old: numbersIter}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+	if _, err = mgr.Get("numbers"); !errors.Is(err, lizt.ErrKeyNotFound) {
+		t.Errorf("wanted ErrKeyNotFound, got error = %v", err)
+	}
+
+	if changes, err = dw.Check(); err != nil || len(changes) != 0 {
+		t.Errorf("expected no change, got %v, error = %v", changes, err)
+	}
+}
+
+func TestManager_WatchDir_ShouldKeepSmartAddDirIters(t *testing.T) {
+	dir := t.TempDir()
+	writeBlacklist(t, filepath.Join(dir, "letters.txt"), []string{"a", "b"}, time.Now().Add(-time.Hour))
+
+	mgr := lizt.NewManager()
+	if err := mgr.SmartAddDirIter(dir, false); err != nil {
+		t.Fatalf("SmartAddDirIter() error = %v", err)
+	}
+	iter := mgr.MustGet("letters")
+
+	dw, err := mgr.WatchDir(dir, lizt.DirWatchOptions{
+		Interval: time.Hour,
+		OnEvent:  func(e lizt.DirEvent) { t.Errorf("expected no event, got %v", e) },
+	})
+	if err != nil {
+		t.Fatalf("WatchDir() error = %v", err)
+	}
+	defer dw.Stop()
+
+	if mgr.MustGet("letters") != iter {
+		t.Errorf("expected the iterator to be kept")
+	}
+}
+
+func TestManager_WatchDir_ShouldNotTouchOtherIterators(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "letters.txt")
+	writeBlacklist(t, path, []string{"a", "b"}, time.Now().Add(-time.Hour))
+
+	mgr := lizt.NewManager()
+	iter := lizt.NewSliceIterator("letters", []string{"x"}, false)
+	mgr.AddIter(iter)
+
+	var errs []error
+	dw, err := mgr.WatchDir(dir, lizt.DirWatchOptions{
+		Interval: time.Hour,
+		OnError:  func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatalf("WatchDir() error = %v", err)
+	}
+	defer dw.Stop()
+
+	if len(errs) != 1 || !errors.Is(errs[0], lizt.ErrKeyExists) {
+		t.Errorf("wanted ErrKeyExists, got %v", errs)
+	}
+	if _, err = dw.Check(); err != nil {
+		t.Errorf("expected the taken name to be reported once, got error = %v", err)
+	}
+
+	if err = os.Remove(path); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err = dw.Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if mgr.MustGet("letters") != iter {
+		t.Errorf("expected the iterator added by AddIter to be kept")
+	}
+}
+
+func TestManager_WatchDir_Polls(t *testing.T) {
+	dir := t.TempDir()
+
+	events := make(chan lizt.DirEvent, 1)
+	mgr := lizt.NewManager()
+	dw, err := mgr.WatchDir(dir, lizt.DirWatchOptions{
+		Interval: 10 * time.Millisecond,
+		OnEvent:  func(e lizt.DirEvent) { events <- e },
+	})
+	if err != nil {
+		t.Fatalf("WatchDir() error = %v", err)
+	}
+	defer dw.Stop()
+
+	writeBlacklist(t, filepath.Join(dir, "letters.txt"), []string{"a"}, time.Now())
+	select {
+	case e := <-events:
+		if e.Type != lizt.DirAdded || e.Name != "letters" {
+			t.Errorf("expected letters to be added, got %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the new file to be added")
+	}
+	if next := mgr.MustGet("letters").MustNextOne(); next != "a" {
+		t.Errorf("expected %s, got %s", "a", next)
+	}
+}
+
+func TestManager_WatchDir_MissingDir(t *testing.T) {
+	_, err := lizt.NewManager().WatchDir(filepath.Join(t.TempDir(), "missing"), lizt.DirWatchOptions{})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("wanted os.ErrNotExist, got error = %v", err)
+	}
+}
+
+func TestManager_WatchDir_ShouldAddSkippedFileOnceNameIsFree(t *testing.T) {
+	dir := t.TempDir()
+	writeBlacklist(t, filepath.Join(dir, "letters.txt"), []string{"a", "b"}, time.Now().Add(-time.Hour))
+
+	mgr := lizt.NewManager().AddIter(lizt.NewSliceIterator("letters", []string{"x"}, false))
+	dw, err := mgr.WatchDir(dir, lizt.DirWatchOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("WatchDir() error = %v", err)
+	}
+	defer dw.Stop()
+
+	if _, err = mgr.Remove("letters"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	changes, err := dw.Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].Type != lizt.DirAdded {
+		t.Errorf("expected letters to be added, got %v", changes)
+	}
+	if next := mgr.MustGet("letters").MustNextOne(); next != "a" {
+		t.Errorf("expected %s, got %s", "a", next)
+	}
+}
+
+func TestManager_WatchDir_ShouldSkipSidecarFiles(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	for _, name := range []string{
+		"pointers", "pointers.lock", "pointers.bak", "pointers.tmp1234", "pointers.bak.tmp",
+		"letters.txt", "letters.txt.idx.tmp42", "notes.tmpl",
+	} {
+		writeBlacklist(t, filepath.Join(dir, name), []string{"a"}, start)
+	}
+
+	mgr := lizt.NewManager()
+	dw, err := mgr.WatchDir(dir, lizt.DirWatchOptions{
+		Interval: time.Hour,
+		OnError:  func(err error) { t.Errorf("expected no error, got %v", err) },
+	})
+	if err != nil {
+		t.Fatalf("WatchDir() error = %v", err)
+	}
+	defer dw.Stop()
+
+	expected := []string{"letters", "notes", "pointers"}
+	if !reflect.DeepEqual(mgr.List(), expected) {
+		t.Errorf("expected %v, got %v", expected, mgr.List())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -18,16 +19,18 @@ func OpenFile(filename string) (*os.File, error) {
 	return f, nil
 }
 
-// ReadFromFile reads a file into a slice of strings
+// ReadFromFile reads a file into a slice of strings. Compressed files are decompressed transparently.
 func ReadFromFile(filename string) ([]string, error) {
 	lc, _ := FileLineCount(filename)
 	idx := 0
 	lines := make([]string, lc)
 
-	file, err := OpenFile(filename)
+	file, err := OpenReader(filename)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		lines[idx] = strings.TrimSpace(scanner.Text())
@@ -50,25 +53,51 @@ func DeleteFile(path string) error {
 	return nil
 }
 
-func FileToMap(pathname string) (map[string]struct{}, error) {
+func FileToMap(pathname string) (BlacklistMap, error) {
 	lines, err := ReadFromFile(pathname)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[string]struct{}, len(lines))
+	m := make(BlacklistMap, len(lines))
 	for _, l := range lines {
 		m[l] = struct{}{}
 	}
 	return m, nil
 }
 
-// FileLineCount returns the number of lines in a file
+// FileToBloomFilter streams a file into a bloom filter sized for its line count at the given false-positive rate,
+// without holding the lines in memory.
+func FileToBloomFilter(pathname string, fpRate float64) (*BloomFilter, error) {
+	lc, err := FileLineCount(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := OpenReader(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bf := NewBloomFilter(uint64(lc), fpRate)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		bf.Add(strings.TrimSpace(scanner.Text()))
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("FileToBloomFilter(): %s -> %w", pathname, scanner.Err())
+	}
+	return bf, nil
+}
+
+// FileLineCount returns the number of lines in a file. Compressed files are decompressed transparently.
 func FileLineCount(filename string) (int, error) {
-	file, err := OpenFile(filename)
+	file, err := OpenReader(filename)
 	if err != nil {
 		return 0, err
 	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	count := 0
@@ -135,6 +164,51 @@ func WriteToFile(lines []string, filename string) error {
 	return nil
 }
 
+// WriteFileAtomic writes data to a temporary file in the same directory, syncs it and renames it over filename,
+// so a crash never leaves a partially written file behind. An existing file keeps its permissions.
+func WriteFileAtomic(filename string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp(): %s -> %w", filename, err)
+	}
+	defer func() {
+		// no-op once the rename succeeded
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("tmp.Write(): %s -> %w", tmp.Name(), err)
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("tmp.Chmod(): %s -> %w", tmp.Name(), err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("tmp.Sync(): %s -> %w", tmp.Name(), err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("tmp.Close(): %s -> %w", tmp.Name(), err)
+	}
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("os.Rename(): %s -> %w", filename, err)
+	}
+
+	// sync the directory so the rename itself survives a crash. not every platform supports this.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
+
 // Shuffle shuffles a slice of strings
 func Shuffle(lines []string) []string {
 	res := make([]string, len(lines))
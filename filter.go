@@ -0,0 +1,117 @@
+package lizt
+
+import (
+	"context"
+	"fmt"
+)
+
+// FilterIterator is an iterator that only emits lines matching a predicate. It keeps reading until it has the
+// requested count, and the pointer of the wrapped iterator counts the skipped lines too, so it stays valid for
+// persistence.
+type FilterIterator struct {
+	PointerIterator
+	fn func(string) bool
+}
+
+// FilterIteratorConfig is the config for a filter iterator. Fn returns true for the lines to keep.
+type FilterIteratorConfig struct {
+	PointerIter PointerIterator
+	Fn          func(string) bool
+}
+
+// NewFilterIterator returns a new filter iterator.
+func NewFilterIterator(cfg FilterIteratorConfig) *FilterIterator {
+	return &FilterIterator{
+		PointerIterator: cfg.PointerIter,
+		fn:              cfg.Fn,
+	}
+}
+
+// Next returns the next matching lines, of a given count, from the iterator.
+func (fi *FilterIterator) Next(count int) ([]string, error) {
+	return fi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next matching lines, of a given count, from the iterator. It stops early if ctx is done.
+// A round-robin iterator without a single match in a whole cycle returns ErrNoMoreLines instead of spinning.
+func (fi *FilterIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	return nextKept(ctx, fi.PointerIterator, "filter", count, true, fi.fn)
+}
+
+// nextKept reads from iter until it has count lines for which keep returns true, and is the loop shared by the
+// iterators that skip lines. It stops early if ctx is done. With guard set, it also stops once a whole cycle of a
+// round-robin iterator was skipped, rather than spinning. Errors are prefixed with op.
+func nextKept(ctx context.Context, iter PointerIterator, op string, count int, guard bool, keep func(string) bool) ([]string, error) {
+	var kept []string
+	skipped := 0
+	for len(kept) < count {
+		if err := contextErr(ctx, iter.Name()); err != nil {
+			return kept, err
+		}
+		if length := iter.Len(); guard && skipped >= length && length > 0 {
+			break
+		}
+
+		next, err := iter.NextContext(ctx, count-len(kept))
+		for _, n := range next {
+			if !keep(n) {
+				skipped++
+				continue
+			}
+			skipped = 0
+			kept = append(kept, n)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return kept, contextErr(ctx, iter.Name())
+			}
+			if len(kept) == 0 {
+				return nil, fmt.Errorf("%s: name: %s -> %w", op, iter.Name(), err)
+			}
+			return kept, nil
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("%s: name: %s -> %w", op, iter.Name(), ErrNoMoreLines)
+	}
+	return kept, nil
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (fi *FilterIterator) MustNext(count int) []string {
+	lines, err := fi.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (fi *FilterIterator) NextOne() (string, error) {
+	lines, err := fi.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (fi *FilterIterator) MustNextOne() string {
+	line, err := fi.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// advance moves the wrapped iterator forward to line p.
+func (fi *FilterIterator) advance(p uint64) {
+	advanceTo(fi.PointerIterator, p)
+}
+
+// Unwrap returns the wrapped iterator.
+func (fi *FilterIterator) Unwrap() PointerIterator {
+	return fi.PointerIterator
+}
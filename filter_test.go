@@ -0,0 +1,94 @@
+package lizt_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+func longerThan(n int) func(string) bool {
+	return func(line string) bool { return len(line) > n }
+}
+
+var words = []string{"a", "bb", "ccc", "d", "eeee", "ff", "ggggg", "h"}
+
+func TestFilterIterator_Next_ShouldFillCount(t *testing.T) {
+	fi := lizt.NewFilterIterator(lizt.FilterIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, words, false),
+		Fn:          longerThan(2),
+	})
+
+	next, err := fi.Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"ccc", "eeee"}) {
+		t.Errorf("expected %v, got %v", []string{"ccc", "eeee"}, next)
+	}
+	if fi.Pointer() != 5 {
+		t.Errorf("expected pointer %d, got %d", 5, fi.Pointer())
+	}
+
+	// asking for more than is left returns what is left.
+	next, err = fi.Next(5)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"ggggg"}) {
+		t.Errorf("expected %v, got %v", []string{"ggggg"}, next)
+	}
+
+	_, err = fi.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestFilterIterator_Next_RoundRobin(t *testing.T) {
+	iter := lizt.B().SliceNamedRR(nameNumbers, words).Filter(longerThan(3)).MustBuild()
+	if next := iter.MustNext(3); !reflect.DeepEqual(next, []string{"eeee", "ggggg", "eeee"}) {
+		t.Errorf("expected %v, got %v", []string{"eeee", "ggggg", "eeee"}, next)
+	}
+
+	// nothing matches, so a whole cycle without a match ends the call.
+	_, err := lizt.B().SliceRR(words).Filter(longerThan(10)).MustBuild().Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestFilterIterator_NextContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	iter := lizt.B().SliceRR(hundredLines()).Filter(func(string) bool {
+		time.Sleep(time.Millisecond)
+		return false
+	}).MustBuild()
+
+	_, err := iter.NextContext(ctx, 5)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted context.DeadlineExceeded, got error = %v", err)
+	}
+}
+
+func TestFilterIterator_Persistent(t *testing.T) {
+	mem := NewInMemoryPersister()
+	p := lizt.B().SliceNamed(nameNumbers, words, false).Filter(longerThan(2)).PersistTo(mem).MustBuild()
+
+	if next := p.MustNext(2); !reflect.DeepEqual(next, []string{"ccc", "eeee"}) {
+		t.Errorf("expected %v, got %v", []string{"ccc", "eeee"}, next)
+	}
+	if mem.pointers[nameNumbers] != 5 {
+		t.Errorf("expected %d, got %d", 5, mem.pointers[nameNumbers])
+	}
+
+	restarted := lizt.B().SliceNamed(nameNumbers, words, false).Filter(longerThan(2)).PersistTo(mem).MustBuild()
+	if next := restarted.MustNext(1); !reflect.DeepEqual(next, []string{"ggggg"}) {
+		t.Errorf("expected %v, got %v", []string{"ggggg"}, next)
+	}
+}
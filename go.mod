@@ -2,6 +2,21 @@ module git.faze.center/netr/lizt
 
 go 1.19
 
-require gopkg.in/ini.v1 v1.67.0
+require (
+	github.com/alicebob/miniredis/v2 v2.31.1
+	github.com/klauspost/compress v1.17.4
+	github.com/redis/go-redis/v9 v9.5.1
+	github.com/ulikunitz/xz v0.5.12
+	go.etcd.io/bbolt v1.3.8
+	golang.org/x/text v0.13.0
+	gopkg.in/ini.v1 v1.67.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require github.com/stretchr/testify v1.8.1 // indirect
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
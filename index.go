@@ -0,0 +1,205 @@
+package lizt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	ErrStaleIndex   = errors.New("stale index")
+	ErrInvalidIndex = errors.New("invalid index")
+)
+
+var (
+	// IndexFileSuffix is appended to a list's filename to get the path of its line-offset index.
+	IndexFileSuffix = ".idx"
+	// IndexStride is how many lines apart the checkpoints of a newly built index are. Seeking reads at most
+	// IndexStride-1 lines past the nearest checkpoint, while the index stays IndexStride times smaller than
+	// one offset per line. For a 50M line list the default index is roughly 1.5MB.
+	IndexStride = 256
+)
+
+// indexMagic identifies (and versions) an index file.
+var indexMagic = [8]byte{'L', 'I', 'Z', 'T', 'I', 'D', 'X', '2'}
+
+// LineIndex maps line numbers to byte offsets in a file. It is stored next to the file and is only valid while
+// the file's size and modification time match the ones it was built from. For compressed files the offsets are
+// positions in the decompressed content.
+type LineIndex struct {
+	filename string
+	size     int64
+	modTime  int64
+	length   int64
+	stride   uint64
+	lines    uint64
+	offsets  []int64
+}
+
+// IndexPath returns the path of the index for the given filename.
+func IndexPath(filename string) string {
+	return filename + IndexFileSuffix
+}
+
+// BuildLineIndex scans the file and records the byte offset of every stride-th line.
+func BuildLineIndex(filename string, stride int) (*LineIndex, error) {
+	if stride < 1 {
+		return nil, fmt.Errorf("stride: %d -> %w", stride, ErrInvalidIndex)
+	}
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("os.Stat(): %s -> %w", filename, err)
+	}
+
+	file, err := OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	li := &LineIndex{
+		filename: filename,
+		size:     stat.Size(),
+		modTime:  stat.ModTime().UnixNano(),
+		stride:   uint64(stride),
+	}
+
+	var pos int64
+	lineStart := true
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		chunk := buf[:n]
+		for len(chunk) > 0 {
+			if lineStart {
+				if li.lines%li.stride == 0 {
+					li.offsets = append(li.offsets, pos)
+				}
+				li.lines++
+				lineStart = false
+			}
+
+			i := bytes.IndexByte(chunk, '\n')
+			if i < 0 {
+				pos += int64(len(chunk))
+				break
+			}
+			pos += int64(i + 1)
+			chunk = chunk[i+1:]
+			lineStart = true
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("BuildLineIndex(): %s -> %w", filename, err)
+		}
+	}
+
+	li.length = pos
+	return li, nil
+}
+
+// LoadLineIndex loads the index stored next to the file. It returns ErrStaleIndex if the file has changed since
+// the index was built.
+func LoadLineIndex(filename string) (*LineIndex, error) {
+	data, err := os.ReadFile(IndexPath(filename))
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(): %s -> %w", IndexPath(filename), err)
+	}
+
+	const header = 8 + 8*6
+	if len(data) < header || !bytes.Equal(data[:8], indexMagic[:]) {
+		return nil, fmt.Errorf("index: %s -> %w", IndexPath(filename), ErrInvalidIndex)
+	}
+
+	li := &LineIndex{
+		filename: filename,
+		size:     int64(binary.LittleEndian.Uint64(data[8:])),
+		modTime:  int64(binary.LittleEndian.Uint64(data[16:])),
+		length:   int64(binary.LittleEndian.Uint64(data[24:])),
+		stride:   binary.LittleEndian.Uint64(data[32:]),
+		lines:    binary.LittleEndian.Uint64(data[40:]),
+	}
+	count := binary.LittleEndian.Uint64(data[48:])
+	if li.stride == 0 || uint64(len(data)-header) != count*8 || count != (li.lines+li.stride-1)/li.stride {
+		return nil, fmt.Errorf("index: %s -> %w", IndexPath(filename), ErrInvalidIndex)
+	}
+
+	li.offsets = make([]int64, count)
+	for i := range li.offsets {
+		li.offsets[i] = int64(binary.LittleEndian.Uint64(data[header+i*8:]))
+	}
+
+	if err = li.validate(); err != nil {
+		return nil, err
+	}
+	return li, nil
+}
+
+// OpenLineIndex loads the index stored next to the file, or builds and saves a new one with IndexStride if there
+// is no index yet or the stored one is stale. Saving is best effort: if the index can't be written, e.g. in a
+// read-only directory, the one built in memory is used and the next open builds it again.
+func OpenLineIndex(filename string) (*LineIndex, error) {
+	if li, err := LoadLineIndex(filename); err == nil {
+		return li, nil
+	}
+
+	li, err := BuildLineIndex(filename, IndexStride)
+	if err != nil {
+		return nil, err
+	}
+	_ = li.Save()
+	return li, nil
+}
+
+// Save writes the index next to the file.
+func (li *LineIndex) Save() error {
+	buf := bytes.NewBuffer(make([]byte, 0, 8+8*6+8*len(li.offsets)))
+	buf.Write(indexMagic[:])
+	for _, v := range []uint64{uint64(li.size), uint64(li.modTime), uint64(li.length), li.stride, li.lines, uint64(len(li.offsets))} {
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	}
+	for _, off := range li.offsets {
+		_ = binary.Write(buf, binary.LittleEndian, off)
+	}
+
+	return WriteFileAtomic(IndexPath(li.filename), buf.Bytes())
+}
+
+// validate returns ErrStaleIndex if the file no longer matches the index.
+func (li *LineIndex) validate() error {
+	stat, err := os.Stat(li.filename)
+	if err != nil {
+		return fmt.Errorf("os.Stat(): %s -> %w", li.filename, err)
+	}
+	if stat.Size() != li.size || stat.ModTime().UnixNano() != li.modTime {
+		return fmt.Errorf("index: %s -> %w", IndexPath(li.filename), ErrStaleIndex)
+	}
+	return nil
+}
+
+// Lines returns the number of lines in the indexed file.
+func (li *LineIndex) Lines() int {
+	return int(li.lines)
+}
+
+// Stride returns how many lines apart the checkpoints are.
+func (li *LineIndex) Stride() int {
+	return int(li.stride)
+}
+
+// Offset returns the byte offset of the nearest checkpoint at or before the given line, and how many lines have to
+// be skipped from there to reach it. Lines past the end map to the end of the file.
+func (li *LineIndex) Offset(line uint64) (offset int64, skip uint64) {
+	if line >= li.lines {
+		return li.length, 0
+	}
+	k := line / li.stride
+	return li.offsets[k], line - k*li.stride
+}
@@ -0,0 +1,250 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+// copyToTemp copies a test file into a temporary directory so sidecar files don't end up in test/.
+func copyToTemp(t *testing.T, filename string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), filepath.Base(filename))
+	if err = os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return dst
+}
+
+func TestBuildLineIndex(t *testing.T) {
+	li, err := lizt.BuildLineIndex(filenameTen, 3)
+	if err != nil {
+		t.Fatalf("BuildLineIndex() error = %v", err)
+	}
+
+	if li.Lines() != 10 {
+		t.Errorf("expected %d lines, got %d", 10, li.Lines())
+	}
+
+	tests := []struct {
+		line   uint64
+		offset int64
+		skip   uint64
+	}{
+		{line: 0, offset: 0, skip: 0},
+		{line: 2, offset: 0, skip: 2},
+		{line: 3, offset: 6, skip: 0},
+		{line: 7, offset: 12, skip: 1},
+		{line: 9, offset: 18, skip: 0},
+		{line: 10, offset: 20, skip: 0},
+	}
+	for _, tt := range tests {
+		offset, skip := li.Offset(tt.line)
+		if offset != tt.offset || skip != tt.skip {
+			t.Errorf("Offset(%d) = (%d, %d), want (%d, %d)", tt.line, offset, skip, tt.offset, tt.skip)
+		}
+	}
+}
+
+func TestLineIndex_SaveAndLoad(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+
+	li, err := lizt.BuildLineIndex(path, 4)
+	if err != nil {
+		t.Fatalf("BuildLineIndex() error = %v", err)
+	}
+	if err = li.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := lizt.LoadLineIndex(path)
+	if err != nil {
+		t.Fatalf("LoadLineIndex() error = %v", err)
+	}
+	if !reflect.DeepEqual(li, loaded) {
+		t.Errorf("expected %v, got %v", li, loaded)
+	}
+}
+
+func TestLineIndex_Load_ShouldDetectStaleIndex(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+
+	if _, err := lizt.OpenLineIndex(path); err != nil {
+		t.Fatalf("OpenLineIndex() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("x\ny\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, future, future)
+
+	_, err := lizt.LoadLineIndex(path)
+	if !errors.Is(err, lizt.ErrStaleIndex) {
+		t.Errorf("wanted ErrStaleIndex, got error = %v", err)
+	}
+
+	li, err := lizt.OpenLineIndex(path)
+	if err != nil {
+		t.Fatalf("OpenLineIndex() error = %v", err)
+	}
+	if li.Lines() != 2 {
+		t.Errorf("expected rebuilt index with %d lines, got %d", 2, li.Lines())
+	}
+}
+
+func TestIndexedStreamIterator_SetPointer(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+
+	fs, err := lizt.NewIndexedStreamIterator(path, true)
+	if err != nil {
+		t.Fatalf("NewIndexedStreamIterator() error = %v", err)
+	}
+	defer fs.Close()
+
+	if !fs.Indexed() || !lizt.DoesFileExist(lizt.IndexPath(path)) {
+		t.Errorf("expected the iterator to build and save an index")
+	}
+
+	fs.SetPointer(7)
+	next, err := fs.Next(5)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+
+	expected := []string{"h", "i", "j", "a", "b"}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+
+	var pointer uint64 = 2
+	if fs.Pointer() != pointer {
+		t.Errorf("expected pointer to be %d, got %d", pointer, fs.Pointer())
+	}
+}
+
+func TestIndexedStreamIterator_ResumesFromPersistedPointer(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+
+	if _, err := lizt.OpenLineIndex(path); err != nil {
+		t.Fatalf("OpenLineIndex() error = %v", err)
+	}
+
+	// a plain stream iterator picks up the existing index.
+	iter, err := lizt.NewStreamIterator(path, false)
+	if err != nil {
+		t.Fatalf("NewStreamIterator() error = %v", err)
+	}
+	if !iter.Indexed() {
+		t.Errorf("expected the existing index to be used")
+	}
+
+	mem := NewInMemoryPersister()
+	mem.pointers["10"] = 4
+
+	p, err := lizt.NewPersistentIterator(lizt.PersistentIteratorConfig{
+		PointerIter: iter,
+		Persister:   mem,
+	})
+	if err != nil {
+		t.Fatalf("NewPersistentIterator() error = %v", err)
+	}
+
+	next, err := p.Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"e", "f"}) {
+		t.Errorf("expected %v, got %v", []string{"e", "f"}, next)
+	}
+}
+
+func TestManager_AddDirIter_ShouldSkipIndexFiles(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+	if _, err := lizt.OpenLineIndex(path); err != nil {
+		t.Fatalf("OpenLineIndex() error = %v", err)
+	}
+
+	mgr := lizt.NewManager()
+	if err := mgr.AddDirIter(filepath.Dir(path), false); err != nil {
+		t.Errorf("AddDirIter() error = %v", err)
+	}
+	if mgr.Len() != 1 {
+		t.Errorf("expected 1, got %d", mgr.Len())
+	}
+}
+
+func TestReadDir_ShouldKeepIndexFilesWithoutList(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "words.idx"), []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	files, err := lizt.ReadDir(dir + "/")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	expected := []string{dir + "/words.idx"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestLineIndex_Load_ShouldRejectOldVersion(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+	if _, err := lizt.OpenLineIndex(path); err != nil {
+		t.Fatalf("OpenLineIndex() error = %v", err)
+	}
+
+	data, err := os.ReadFile(lizt.IndexPath(path))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	copy(data, "LIZTIDX1")
+	if err = os.WriteFile(lizt.IndexPath(path), data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err = lizt.LoadLineIndex(path); !errors.Is(err, lizt.ErrInvalidIndex) {
+		t.Errorf("wanted ErrInvalidIndex, got error = %v", err)
+	}
+	if _, err = lizt.OpenLineIndex(path); err != nil {
+		t.Errorf("expected the index to be rebuilt, got error = %v", err)
+	}
+	if _, err = lizt.LoadLineIndex(path); err != nil {
+		t.Errorf("LoadLineIndex() error = %v", err)
+	}
+}
+
+func TestOpenLineIndex_ShouldKeepIndexThatCantBeSaved(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+	// a directory where the index should go makes saving it fail
+	if err := os.Mkdir(lizt.IndexPath(path), 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+
+	stream, err := lizt.NewIndexedStreamIterator(path, false)
+	if err != nil {
+		t.Fatalf("NewIndexedStreamIterator() error = %v", err)
+	}
+	defer stream.Close()
+
+	if !stream.Indexed() {
+		t.Errorf("expected the stream to use the index built in memory")
+	}
+	stream.SetPointer(5)
+	if line := stream.MustNextOne(); line != "f" {
+		t.Errorf("expected %s, got %s", "f", line)
+	}
+}
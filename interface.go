@@ -1,10 +1,14 @@
 package lizt
 
+import "context"
+
 // Iterator is an interface for iterating over a list of lines.
+// NextContext stops once ctx is done. Lines consumed before that point are returned alongside the error.
 type Iterator interface {
 	Name() string
 	Len() int
 	Next(count int) ([]string, error)
+	NextContext(ctx context.Context, count int) ([]string, error)
 	NextOne() (string, error)
 	MustNext(count int) []string
 	MustNextOne() string
@@ -24,10 +28,27 @@ type Seeder interface {
 	Planted() int64
 }
 
-// Persister adds persistent storage to an iterator.
+// Persister adds persistent storage to an iterator. Increment and CompareAndSwap are atomic, even when the storage
+// is shared with other processes, and treat a missing key as 0.
 type Persister interface {
 	Set(key string, value uint64) error
 	Get(key string) (uint64, error)
+	Increment(key string, delta uint64) (uint64, error)
+	CompareAndSwap(key string, old, new uint64) (bool, error)
+}
+
+// TxPersister is a persister with transactions. Update runs fn with a persister whose writes are committed together
+// when fn returns nil and discarded otherwise. PersistentIterator and LeasingIterator save the pointer and their
+// state in one transaction, so a crash never leaves them out of step.
+type TxPersister interface {
+	Persister
+	Update(fn func(tx Persister) error) error
+}
+
+// Deleter is implemented by persisters that can delete keys. Iterators that drop a key delete it if the persister is
+// a Deleter and overwrite it otherwise. Deleting a missing key isn't an error.
+type Deleter interface {
+	Delete(key string) error
 }
 
 // Blacklister adds blacklisting capabilities to an iterator
@@ -35,3 +56,22 @@ type Blacklister interface {
 	Blacklist() map[string]struct{}
 	IsBlacklisted(string) bool
 }
+
+// BlacklistBackend is a set of blacklisted lines. BlacklistManager, BlacklistMap, BloomFilter and
+// SortedFileBlacklist implement it, so the memory cost of a blacklist can be traded for accuracy or disk reads.
+// RuleBlacklist matches patterns instead of lines.
+type BlacklistBackend interface {
+	Has(line string) bool
+}
+
+// Stateful is implemented by iterators that carry state besides the pointer. A PersistentIterator saves every key
+// as "<name>.<key>" next to the pointer and restores it on start up.
+type Stateful interface {
+	State() map[string]uint64
+	SetState(map[string]uint64)
+}
+
+// Unwrapper is implemented by iterators that wrap another iterator.
+type Unwrapper interface {
+	Unwrap() PointerIterator
+}
@@ -0,0 +1,355 @@
+package lizt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// DefaultLeaseTimeout is the visibility timeout used when LeasingIteratorConfig.Timeout is zero.
+var DefaultLeaseTimeout = 5 * time.Minute
+
+// leaseFree marks a free lease slot in persisters that can't delete keys.
+const leaseFree = math.MaxUint64
+
+// Lease is a line handed out by a LeasingIterator. It is redelivered unless it is acknowledged before the deadline.
+type Lease struct {
+	Deadline time.Time
+	Line     string
+	ID       uint64
+	Index    uint64
+	Attempts int
+	slot     int
+}
+
+// LeasingIterator is an iterator with work-queue semantics. Every line it hands out is leased for a visibility
+// timeout and comes back from Next if it isn't acknowledged with Ack in time, or right away after a Nack.
+// The pointer and the outstanding leases are saved to the Persister after every change, so a restart redelivers
+// the lines that were in flight instead of dropping them.
+//
+// Every outstanding lease occupies a slot, saved as "<name>.leases.<slot>", and "<name>.leases" is the number of
+// slots. Only the slots that change are written; the slot of an acknowledged lease is deleted and reused.
+type LeasingIterator struct {
+	PointerIterator
+	persister Persister
+	leases    map[uint64]*Lease
+	slots     []bool
+	saved     int
+	nextID    uint64
+	timeout   time.Duration
+	mu        sync.Mutex
+}
+
+// LeasingIteratorConfig is the config for a leasing iterator.
+type LeasingIteratorConfig struct {
+	PointerIter PointerIterator
+	Persister   Persister
+	Timeout     time.Duration
+}
+
+// NewLeasingIterator returns a new leasing iterator. It restores the pointer and the outstanding leases from the
+// persister. Restored leases are redelivered by the next call to Next.
+func NewLeasingIterator(cfg LeasingIteratorConfig) (*LeasingIterator, error) {
+	li := &LeasingIterator{
+		PointerIterator: cfg.PointerIter,
+		persister:       cfg.Persister,
+		leases:          make(map[uint64]*Lease),
+		timeout:         cfg.Timeout,
+	}
+	if li.timeout <= 0 {
+		li.timeout = DefaultLeaseTimeout
+	}
+
+	val, err := cfg.Persister.Get(li.Name())
+	if errors.Is(err, ErrCorruptState) {
+		return nil, fmt.Errorf("lease: name: %s -> %w", li.Name(), err)
+	}
+	if err == nil {
+		li.PointerIterator.SetPointer(val)
+	}
+
+	if err := li.restore(); err != nil {
+		return nil, err
+	}
+	return li, nil
+}
+
+// leaseKey returns the persister key of a lease slot.
+func (li *LeasingIterator) leaseKey(slot int) string {
+	return fmt.Sprintf("%s.leases.%d", li.Name(), slot)
+}
+
+// restore reads the outstanding leases from the persister and fetches their lines. A missing lease count means
+// there are no outstanding leases, while a corrupt one is an error.
+func (li *LeasingIterator) restore() error {
+	count, err := li.persister.Get(li.Name() + ".leases")
+	if errors.Is(err, ErrCorruptState) {
+		return fmt.Errorf("lease: name: %s: restore leases -> %w", li.Name(), err)
+	}
+	if err != nil {
+		return nil
+	}
+
+	pointer := li.PointerIterator.Pointer()
+	defer li.PointerIterator.SetPointer(pointer)
+
+	li.slots = make([]bool, count)
+	for slot := range li.slots {
+		idx, err := li.persister.Get(li.leaseKey(slot))
+		if errors.Is(err, ErrCorruptState) {
+			return fmt.Errorf("lease: name: %s: restore lease %d -> %w", li.Name(), slot, err)
+		}
+		if err != nil || idx == leaseFree {
+			continue
+		}
+
+		li.PointerIterator.SetPointer(idx)
+		next, err := li.PointerIterator.Next(1)
+		if err != nil {
+			return fmt.Errorf("lease: name: %s: restore line %d -> %w", li.Name(), idx, err)
+		}
+
+		li.nextID++
+		li.leases[li.nextID] = &Lease{ID: li.nextID, Index: idx, Line: next[0], Attempts: 1, slot: slot}
+		li.slots[slot] = true
+	}
+	li.saved = int(count)
+	return nil
+}
+
+// takeSlot returns the lowest free lease slot.
+func (li *LeasingIterator) takeSlot() int {
+	for slot, used := range li.slots {
+		if !used {
+			li.slots[slot] = true
+			return slot
+		}
+	}
+	li.slots = append(li.slots, true)
+	return len(li.slots) - 1
+}
+
+// save persists the new leases, frees the slots of the acknowledged ones, and then saves the pointer. If the
+// process dies in between, lines are redelivered rather than lost. With a TxPersister they're written in one
+// transaction.
+func (li *LeasingIterator) save(added []*Lease, freed []int) error {
+	for _, slot := range freed {
+		li.slots[slot] = false
+	}
+	count := len(li.slots)
+	for count > 0 && !li.slots[count-1] {
+		count--
+	}
+
+	err := updatePersister(li.persister, func(p Persister) error {
+		for _, l := range added {
+			if err := p.Set(li.leaseKey(l.slot), l.Index); err != nil {
+				return err
+			}
+		}
+		for _, slot := range freed {
+			if err := deleteKey(p, li.leaseKey(slot), leaseFree); err != nil {
+				return err
+			}
+		}
+		if count != li.saved {
+			if err := p.Set(li.Name()+".leases", uint64(count)); err != nil {
+				return err
+			}
+		}
+		return p.Set(li.Name(), li.PointerIterator.Pointer())
+	})
+	if err != nil {
+		return fmt.Errorf("lease: name: %s -> %w", li.Name(), err)
+	}
+	li.slots = li.slots[:count]
+	li.saved = count
+	return nil
+}
+
+// deleteKey deletes a key if p is a Deleter, and sets it to tombstone otherwise.
+func deleteKey(p Persister, key string, tombstone uint64) error {
+	if d, ok := p.(Deleter); ok {
+		return d.Delete(key)
+	}
+	return p.Set(key, tombstone)
+}
+
+// sortedLeases returns the outstanding leases in the order they were first handed out.
+func (li *LeasingIterator) sortedLeases() []*Lease {
+	leases := make([]*Lease, 0, len(li.leases))
+	for _, l := range li.leases {
+		leases = append(leases, l)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].ID < leases[j].ID })
+	return leases
+}
+
+// NextLeases leases the next lines, of a given count. Expired and nacked leases are redelivered before new lines
+// are read from the underlying iterator.
+func (li *LeasingIterator) NextLeases(count int) ([]Lease, error) {
+	return li.NextLeasesContext(context.Background(), count)
+}
+
+// NextLeasesContext leases the next lines, of a given count. It stops early if ctx is done.
+func (li *LeasingIterator) NextLeasesContext(ctx context.Context, count int) ([]Lease, error) {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+
+	now := time.Now()
+	deadline := now.Add(li.timeout)
+
+	var leases []Lease
+	for _, l := range li.sortedLeases() {
+		if len(leases) == count {
+			break
+		}
+		if l.Deadline.After(now) {
+			continue
+		}
+		l.Deadline = deadline
+		l.Attempts++
+		leases = append(leases, *l)
+	}
+
+	var (
+		added   []*Lease
+		nextErr error
+	)
+	for len(leases) < count {
+		// the pointer before the read is the line's index, even if the wrapped iterator moves by more than one
+		idx := li.PointerIterator.Pointer()
+		next, err := li.PointerIterator.NextContext(ctx, 1)
+		if err != nil {
+			nextErr = err
+			break
+		}
+
+		li.nextID++
+		l := &Lease{ID: li.nextID, Index: idx, Line: next[0], Deadline: deadline, Attempts: 1, slot: li.takeSlot()}
+		li.leases[l.ID] = l
+		added = append(added, l)
+		leases = append(leases, *l)
+	}
+
+	if err := li.save(added, nil); err != nil {
+		return nil, err
+	}
+
+	if nextErr != nil {
+		if ctx.Err() != nil {
+			return leases, contextErr(ctx, li.Name())
+		}
+		if len(leases) == 0 {
+			return nil, fmt.Errorf("lease: name: %s -> %w", li.Name(), nextErr)
+		}
+	}
+	return leases, nil
+}
+
+// Ack acknowledges leases, which removes them for good.
+func (li *LeasingIterator) Ack(ids ...uint64) error {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := li.leases[id]; !ok {
+			return fmt.Errorf("lease: name: %s: id: %d -> %w", li.Name(), id, ErrLeaseNotFound)
+		}
+	}
+
+	freed := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if l, ok := li.leases[id]; ok {
+			delete(li.leases, id)
+			freed = append(freed, l.slot)
+		}
+	}
+	return li.save(nil, freed)
+}
+
+// Nack returns leases, so they are redelivered by the next call to Next. The leases are saved again, so they're
+// redelivered after a restart as well.
+func (li *LeasingIterator) Nack(ids ...uint64) error {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+
+	nacked := make([]*Lease, 0, len(ids))
+	for _, id := range ids {
+		l, ok := li.leases[id]
+		if !ok {
+			return fmt.Errorf("lease: name: %s: id: %d -> %w", li.Name(), id, ErrLeaseNotFound)
+		}
+		nacked = append(nacked, l)
+	}
+
+	for _, l := range nacked {
+		l.Deadline = time.Time{}
+	}
+	return li.save(nacked, nil)
+}
+
+// Outstanding returns the number of leases that haven't been acknowledged.
+func (li *LeasingIterator) Outstanding() int {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+
+	return len(li.leases)
+}
+
+// Next leases the next lines, of a given count. Use NextLeases to get the lease ids needed for Ack.
+func (li *LeasingIterator) Next(count int) ([]string, error) {
+	return li.NextContext(context.Background(), count)
+}
+
+// NextContext leases the next lines, of a given count. It stops early if ctx is done.
+func (li *LeasingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	leases, err := li.NextLeasesContext(ctx, count)
+	if err != nil && len(leases) == 0 {
+		return nil, err
+	}
+
+	lines := make([]string, len(leases))
+	for i, l := range leases {
+		lines[i] = l.Line
+	}
+	return lines, err
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (li *LeasingIterator) MustNext(count int) []string {
+	lines, err := li.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (li *LeasingIterator) NextOne() (string, error) {
+	lines, err := li.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (li *LeasingIterator) MustNextOne() string {
+	line, err := li.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// Unwrap returns the wrapped iterator.
+func (li *LeasingIterator) Unwrap() PointerIterator {
+	return li.PointerIterator
+}
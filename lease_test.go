@@ -0,0 +1,297 @@
+package lizt_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+func leaseLines(leases []lizt.Lease) []string {
+	lines := make([]string, len(leases))
+	for i, l := range leases {
+		lines[i] = l.Line
+	}
+	return lines
+}
+
+func TestLeasingIterator_Ack(t *testing.T) {
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   NewInMemoryPersister(),
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	leases, err := li.NextLeases(3)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+	if !reflect.DeepEqual(leaseLines(leases), []string{"1", "2", "3"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "2", "3"}, leaseLines(leases))
+	}
+
+	if err = li.Ack(leases[0].ID, leases[2].ID); err != nil {
+		t.Errorf("Ack() error = %v", err)
+	}
+	if li.Outstanding() != 1 {
+		t.Errorf("expected %d outstanding, got %d", 1, li.Outstanding())
+	}
+
+	err = li.Ack(leases[0].ID)
+	if !errors.Is(err, lizt.ErrLeaseNotFound) {
+		t.Errorf("wanted ErrLeaseNotFound, got error = %v", err)
+	}
+}
+
+func TestLeasingIterator_ShouldRedeliverExpiredLeases(t *testing.T) {
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   NewInMemoryPersister(),
+		Timeout:     20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	first, err := li.NextLeases(2)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	second, err := li.NextLeases(3)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+	if !reflect.DeepEqual(leaseLines(second), []string{"1", "2", "3"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "2", "3"}, leaseLines(second))
+	}
+	if second[0].ID != first[0].ID || second[0].Attempts != 2 {
+		t.Errorf("expected lease %d to be redelivered, got %+v", first[0].ID, second[0])
+	}
+}
+
+func TestLeasingIterator_Nack(t *testing.T) {
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   NewInMemoryPersister(),
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	first, err := li.NextLeases(2)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+	if err = li.Nack(first[1].ID); err != nil {
+		t.Errorf("Nack() error = %v", err)
+	}
+
+	next, err := li.Next(2)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"2", "3"}) {
+		t.Errorf("expected %v, got %v", []string{"2", "3"}, next)
+	}
+}
+
+func TestLeasingIterator_ShouldRedeliverOutstandingLeasesAfterRestart(t *testing.T) {
+	mem := NewInMemoryPersister()
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   mem,
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	leases, err := li.NextLeases(3)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+	if err = li.Ack(leases[1].ID); err != nil {
+		t.Errorf("Ack() error = %v", err)
+	}
+
+	if mem.pointers[nameNumbers] != 3 || mem.pointers[nameNumbers+".leases"] != 3 {
+		t.Errorf("expected pointer and leases to be persisted, got %v", mem.pointers)
+	}
+	if _, ok := mem.pointers[nameNumbers+".leases.1"]; ok {
+		t.Errorf("expected the acknowledged lease to be deleted, got %v", mem.pointers)
+	}
+
+	restarted, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   mem,
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+	if restarted.Outstanding() != 2 {
+		t.Errorf("expected %d outstanding, got %d", 2, restarted.Outstanding())
+	}
+
+	next, err := restarted.Next(5)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"1", "3", "4", "5"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "3", "4", "5"}, next)
+	}
+
+	_, err = restarted.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestLeasingIterator_ShouldReuseSlotsOfAcknowledgedLeases(t *testing.T) {
+	mem := NewInMemoryPersister()
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   mem,
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		leases, err := li.NextLeases(1)
+		if err != nil {
+			t.Fatalf("NextLeases() error = %v", err)
+		}
+		if err = li.Ack(leases[0].ID); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+
+	expected := map[string]uint64{nameNumbers: 5, nameNumbers + ".leases": 0}
+	if !reflect.DeepEqual(mem.pointers, expected) {
+		t.Errorf("expected %v, got %v", expected, mem.pointers)
+	}
+}
+
+// nonDeletingPersister hides the Delete method of the persister it wraps.
+type nonDeletingPersister struct {
+	lizt.Persister
+}
+
+func TestLeasingIterator_ShouldFreeSlotsWithoutDeleter(t *testing.T) {
+	mem := NewInMemoryPersister()
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3"}, false),
+		Persister:   nonDeletingPersister{mem},
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	leases, err := li.NextLeases(3)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+	if err = li.Ack(leases[1].ID); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if mem.pointers[nameNumbers+".leases.1"] != math.MaxUint64 {
+		t.Errorf("expected the acknowledged lease to be freed, got %v", mem.pointers)
+	}
+
+	restarted, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3"}, false),
+		Persister:   nonDeletingPersister{mem},
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+	if next := restarted.MustNext(2); !reflect.DeepEqual(next, []string{"1", "3"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "3"}, next)
+	}
+}
+
+func TestLeasingIterator_Nack_ShouldPersist(t *testing.T) {
+	mem := NewInMemoryPersister()
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2", "3", "4", "5"}, false),
+		Persister:   mem,
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	leases, err := li.NextLeases(2)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+	delete(mem.pointers, nameNumbers+".leases.1")
+	if err = li.Nack(leases[1].ID); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if mem.pointers[nameNumbers+".leases.1"] != 1 {
+		t.Errorf("expected the nacked lease to be saved, got %v", mem.pointers)
+	}
+}
+
+func TestLeasingIterator_ShouldIndexLeasesUnderASeeder(t *testing.T) {
+	seeded := lizt.B().SliceNamed(nameNumbers, []string{"1", "2", "3", "4"}, false).MustBuildWithSeeds(2, []string{"s"})
+	li, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{PointerIter: seeded, Persister: NewInMemoryPersister()})
+	if err != nil {
+		t.Fatalf("NewLeasingIterator() error = %v", err)
+	}
+
+	leases, err := li.NextLeases(4)
+	if err != nil {
+		t.Fatalf("NextLeases() error = %v", err)
+	}
+
+	// a seed doesn't move the pointer, so every lease is indexed by the pointer before it was read
+	expected := []uint64{0, 0, 1, 1}
+	for i, l := range leases {
+		if l.Index != expected[i] {
+			t.Errorf("lease %d (%s): expected index %d, got %d", i, l.Line, expected[i], l.Index)
+		}
+	}
+}
+
+// corruptKeyPersister is an InMemoryPersister that can't read one of its keys.
+type corruptKeyPersister struct {
+	*InMemoryPersister
+	key string
+}
+
+func (p corruptKeyPersister) Get(key string) (uint64, error) {
+	if key == p.key {
+		return 0, lizt.ErrCorruptState
+	}
+	return p.InMemoryPersister.Get(key)
+}
+
+func TestLeasingIterator_CorruptState(t *testing.T) {
+	for _, key := range []string{nameNumbers, nameNumbers + ".leases", nameNumbers + ".leases.0"} {
+		mem := NewInMemoryPersister()
+		_ = mem.Set(nameNumbers+".leases", 1)
+		_ = mem.Set(nameNumbers+".leases.0", 0)
+
+		_, err := lizt.NewLeasingIterator(lizt.LeasingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2"}, false),
+			Persister:   corruptKeyPersister{InMemoryPersister: mem, key: key},
+		})
+		if !errors.Is(err, lizt.ErrCorruptState) {
+			t.Errorf("%s: wanted ErrCorruptState, got error = %v", key, err)
+		}
+	}
+}
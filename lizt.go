@@ -1,39 +1,67 @@
 package lizt
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"sort"
 	"strings"
+	"sync"
 )
 
 var (
 	ErrNoMoreLines       = errors.New("no more lines")
 	ErrKeyNotFound       = errors.New("key not found")
 	ErrPointerOutOfRange = errors.New("pointer out of range")
+	ErrCorruptState      = errors.New("corrupt state")
+	ErrKeyExists         = errors.New("key exists")
+	ErrNoSource          = errors.New("no source file")
+)
+
+// LargeFileIterator selects the iterator SmartAddDirIter uses for files with more than MaxLinesForSliceIter lines.
+type LargeFileIterator int
+
+const (
+	LargeFileStream LargeFileIterator = iota
+	LargeFileMmap
 )
 
 var (
-	MaxLinesForSliceIter = 250_000
+	MaxLinesForSliceIter   = 250_000
+	SmartLargeFileIterator = LargeFileStream
 )
 
-// Manager manages iterators.
+// Manager manages iterators. It's safe for concurrent use.
 type Manager struct {
-	files map[string]Iterator
+	files   map[string]Iterator
+	sources map[string]source
+	err     error
+	mu      sync.RWMutex
+}
+
+// source is the file an iterator of the manager was created from, so it can be reloaded.
+type source struct {
+	path       string
+	roundRobin bool
+	smart      bool
 }
 
 // NewManager returns a new manager.
 func NewManager() *Manager {
 	return &Manager{
-		files: make(map[string]Iterator, 0),
+		files:   make(map[string]Iterator, 0),
+		sources: make(map[string]source, 0),
 	}
 }
 
 // List returns a list of the names of the iterators.
 func (m *Manager) List() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	var names []string
 	for name := range m.files {
 		names = append(names, name)
@@ -43,28 +71,168 @@ func (m *Manager) List() []string {
 	return names
 }
 
-// AddIter adds an iterator to the manager.
+// AddIter adds an iterator to the manager. If the name is taken, the iterator isn't added and an error wrapping
+// ErrKeyExists is recorded, which Err returns, so calls can still be chained. Use Replace to swap an iterator.
 func (m *Manager) AddIter(i Iterator) *Manager {
-	m.files[i.Name()] = i
-	return m
+	return m.AddIters(i)
 }
 
-// AddIters adds a slice of iterators to the manager.
+// AddIters adds a slice of iterators to the manager. If any name is taken, none of them are added and an error
+// wrapping ErrKeyExists is recorded, which Err returns.
 func (m *Manager) AddIters(iters ...Iterator) *Manager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.add(iters, nil); err != nil && m.err == nil {
+		m.err = err
+	}
+	return m
+}
+
+// Err returns the first name collision of AddIter or AddIters, or nil if there was none.
+func (m *Manager) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.err
+}
+
+// add adds iterators, and their sources if sources isn't nil. m.mu must be held.
+func (m *Manager) add(iters []Iterator, sources []source) error {
+	names := make(map[string]struct{}, len(iters))
 	for _, iter := range iters {
+		name := iter.Name()
+		if _, ok := names[name]; ok || m.files[name] != nil {
+			return fmt.Errorf("key: %s -> %w", name, ErrKeyExists)
+		}
+		names[name] = struct{}{}
+	}
+
+	for i, iter := range iters {
 		m.files[iter.Name()] = iter
+		if sources != nil {
+			m.sources[iter.Name()] = sources[i]
+		}
+	}
+	return nil
+}
+
+// Remove removes an iterator from the manager and returns it. It returns ErrKeyNotFound if there's none.
+func (m *Manager) Remove(name string) (Iterator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	iter := m.files[name]
+	if iter == nil {
+		return nil, fmt.Errorf("key: %s -> %w", name, ErrKeyNotFound)
+	}
+	delete(m.files, name)
+	delete(m.sources, name)
+	return iter, nil
+}
+
+// Replace swaps the iterator with the same name as i for i and returns the old one. It returns ErrKeyNotFound if
+// there's none. The replaced iterator can't be reloaded anymore, because i didn't come from its file.
+func (m *Manager) Replace(i Iterator) (Iterator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := m.files[i.Name()]
+	if old == nil {
+		return nil, fmt.Errorf("key: %s -> %w", i.Name(), ErrKeyNotFound)
+	}
+	m.files[i.Name()] = i
+	delete(m.sources, i.Name())
+	return old, nil
+}
+
+// Reload re-reads the file of an iterator added by AddDirIter or SmartAddDirIter, swaps it in and returns the old
+// one. The pointer is kept if it's still within the file, and reset otherwise. Like with Remove and Replace, the old
+// iterator isn't closed, since others may still be reading it: close it once they're done. It returns ErrNoSource for
+// iterators added by AddIter.
+func (m *Manager) Reload(name string) (Iterator, error) {
+	m.mu.RLock()
+	src, ok := m.sources[name]
+	m.mu.RUnlock()
+	if !ok {
+		if _, err := m.Get(name); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("key: %s -> %w", name, ErrNoSource)
+	}
+
+	iter, err := src.open()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	old := m.files[name]
+	if cur, ok := m.sources[name]; !ok || cur != src {
+		// removed or replaced while the file was read
+		m.mu.Unlock()
+		closeIter(iter)
+		if old == nil {
+			return nil, fmt.Errorf("key: %s -> %w", name, ErrKeyNotFound)
+		}
+		return nil, fmt.Errorf("key: %s -> %w", name, ErrNoSource)
+	}
+	if oldPi, ok := old.(PointerIterator); ok {
+		if pi, ok := iter.(PointerIterator); ok && oldPi.Pointer() <= uint64(pi.Len()) {
+			pi.SetPointer(oldPi.Pointer())
+		}
+	}
+	m.files[name] = iter
+	m.mu.Unlock()
+
+	return old, nil
+}
+
+// open creates the iterator of the source file.
+func (s source) open() (Iterator, error) {
+	if s.smart {
+		return newSmartIter(s.path, s.roundRobin)
+	}
+
+	lines, err := ReadFromFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("read from file: %s -> %w", s.path, err)
+	}
+	return NewSliceIterator(makeNameFromFilename(s.path), lines, s.roundRobin), nil
+}
+
+// closeIter closes an iterator if it has a Close method.
+func closeIter(iter Iterator) {
+	if c, ok := iter.(io.Closer); ok {
+		_ = c.Close()
 	}
-	return m
 }
 
 // Len returns the length of the iterator.
 func (m *Manager) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return len(m.files)
 }
 
 // AddDirIter walks a directory of files, converts the files into SliceIterators, and adds them to the manager.
 // This will always be faster than SmartAddDirIter(). However, it will not take size into account.
+// It returns ErrKeyExists, and adds none of the files, if any name is taken.
 func (m *Manager) AddDirIter(dir string, roundRobin bool) error {
+	return m.addDir(dir, roundRobin, false)
+}
+
+// SmartAddDirIter walks a directory of files, converts the files into Iterators (while taking line count into account), and adds them to the manager.
+// Files with less than MaxLinesForSliceIter lines will be SliceIterators, the rest will be StreamIterators (or MmapIterators when
+// SmartLargeFileIterator is LargeFileMmap).
+// This will always be slower than just running AddDirIter(), because we have to count the lines in each file.
+// It returns ErrKeyExists, and adds none of the files, if any name is taken.
+func (m *Manager) SmartAddDirIter(dir string, roundRobin bool) error {
+	return m.addDir(dir, roundRobin, true)
+}
+
+func (m *Manager) addDir(dir string, roundRobin, smart bool) error {
 	if !strings.HasSuffix(dir, "/") {
 		dir += "/"
 	}
@@ -73,56 +241,57 @@ func (m *Manager) AddDirIter(dir string, roundRobin bool) error {
 	if err != nil {
 		return err
 	}
+
+	iters := make([]Iterator, 0, len(files))
+	sources := make([]source, 0, len(files))
 	for _, f := range files {
-		name := makeNameFromFilename(f)
-		lines, err := ReadFromFile(f)
+		src := source{path: f, roundRobin: roundRobin, smart: smart}
+		iter, err := src.open()
 		if err != nil {
-			return fmt.Errorf("read from file: %s -> %w", f, err)
+			for _, iter := range iters {
+				closeIter(iter)
+			}
+			return err
 		}
-		si := NewSliceIterator(name, lines, roundRobin)
-		m.AddIter(si)
+		iters = append(iters, iter)
+		sources = append(sources, src)
 	}
 
-	return nil
-}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-// SmartAddDirIter walks a directory of files, converts the files into Iterators (while taking line count into account), and adds them to the manager.
-// Files with less than MaxLinesForSliceIter lines will be SliceIterators, the rest will be StreamIterators.
-// This will always be slower than just running AddDirIter(), because we have to count the lines in each file.
-func (m *Manager) SmartAddDirIter(dir string, roundRobin bool) error {
-	if !strings.HasSuffix(dir, "/") {
-		dir += "/"
+	if err = m.add(iters, sources); err != nil {
+		for _, iter := range iters {
+			closeIter(iter)
+		}
+		return err
 	}
+	return nil
+}
 
-	files, err := ReadDir(dir)
+// newSmartIter creates a SliceIterator for small files and a StreamIterator or MmapIterator for large ones.
+func newSmartIter(f string, roundRobin bool) (Iterator, error) {
+	lines, err := FileLineCount(f)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("count lines from file: %s -> %w", f, err)
 	}
-	for _, f := range files {
-		lines, err := FileLineCount(f)
-		if err != nil {
-			return fmt.Errorf("count lines from file: %s -> %w", f, err)
-		}
 
-		if lines > MaxLinesForSliceIter {
-			si, err := NewStreamIterator(f, roundRobin)
-			if err != nil {
-				return err
+	if lines > MaxLinesForSliceIter {
+		if SmartLargeFileIterator == LargeFileMmap {
+			// compressed files can't be mapped, so they fall back to streams.
+			if c, err := DetectCompression(f); err == nil && c == CompressionNone {
+				return NewMmapIterator(f, roundRobin)
 			}
-			m.AddIter(si)
-		} else {
-			name := makeNameFromFilename(f)
-			lines, err := ReadFromFile(f)
-			if err != nil {
-				return fmt.Errorf("read from file: %s -> %w", f, err)
-			}
-			si := NewSliceIterator(name, lines, roundRobin)
-			m.AddIter(si)
 		}
-
+		return NewStreamIterator(f, roundRobin)
 	}
 
-	return nil
+	name := makeNameFromFilename(f)
+	content, err := ReadFromFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("read from file: %s -> %w", f, err)
+	}
+	return NewSliceIterator(name, content, roundRobin), nil
 }
 
 func ReadDir(dir string) ([]string, error) {
@@ -130,17 +299,66 @@ func ReadDir(dir string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read dir: %w", err)
 	}
-	var files []string
+	names := make(map[string]struct{}, len(readDir))
 	for _, entry := range readDir {
 		if !entry.IsDir() {
+			names[entry.Name()] = struct{}{}
+		}
+	}
+
+	var files []string
+	for _, entry := range readDir {
+		if !entry.IsDir() && !isSidecar(entry.Name(), names) {
 			files = append(files, dir+entry.Name())
 		}
 	}
 	return files, nil
 }
 
+// sidecarSuffixes are the suffixes of the files kept next to a file: its line index, and the lock and backup of a
+// persister file.
+var sidecarSuffixes = []string{IndexFileSuffix, ".lock", ".bak"}
+
+// isSidecar reports whether a file is the index, lock or backup of another file in the same directory, or a temp
+// file WriteFileAtomic left of one of them, so a list that merely ends in one of the suffixes is still read.
+func isSidecar(name string, names map[string]struct{}) bool {
+	if base, ok := trimTempSuffix(name); ok {
+		if _, ok = names[base]; ok {
+			return true
+		}
+		return isSidecar(base, names)
+	}
+
+	for _, suffix := range sidecarSuffixes {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		if _, ok := names[strings.TrimSuffix(name, suffix)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// trimTempSuffix removes the suffix of a temp file, ".tmp" followed by the digits os.CreateTemp adds, if any.
+func trimTempSuffix(name string) (string, bool) {
+	i := strings.LastIndex(name, ".tmp")
+	if i < 0 {
+		return name, false
+	}
+	for _, c := range name[i+len(".tmp"):] {
+		if c < '0' || c > '9' {
+			return name, false
+		}
+	}
+	return name[:i], true
+}
+
 // Get returns the next line from the iterator.
 func (m *Manager) Get(name string) (Iterator, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	if m.files[name] == nil {
 		return nil, fmt.Errorf("key: %s -> %w", name, ErrKeyNotFound)
 	}
@@ -149,23 +367,25 @@ func (m *Manager) Get(name string) (Iterator, error) {
 
 // MustGet returns the next line from the iterator.
 func (m *Manager) MustGet(name string) Iterator {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return m.files[name]
 }
 
-// makeNameFromFilename takes a filename and returns a name.
+// makeNameFromFilename takes a filename and returns a name. A compression suffix is stripped, so `words.txt.gz` is `words`.
 func makeNameFromFilename(filename string) string {
-	p := path.Clean(filename)
+	p := path.Clean(trimCompressionSuffix(filename))
 	ps := strings.Split(p, "/")
 	p = ps[len(ps)-1]
 	ps = strings.Split(p, ".")
 	return ps[0]
 }
 
-// newFileReader returns a new file reader.
-func newFileReader(filename string) (*bufio.Reader, error) {
-	file, err := OpenFile(filename)
-	if err != nil {
-		return nil, err
+// contextErr returns the context error wrapped with the iterator name, or nil if the context is still active.
+func contextErr(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context: name: %s -> %w", name, err)
 	}
-	return bufio.NewReader(file), nil
+	return nil
 }
@@ -1,7 +1,13 @@
 package lizt_test
 
 import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 
 	"git.faze.center/netr/lizt"
@@ -152,3 +158,179 @@ func TestManager_SmartAddDirIter(t *testing.T) {
 		t.Errorf("%s: expected SliceIterator, got %s", "10", reflect.TypeOf(tenIter).Elem().Name())
 	}
 }
+
+func TestManager_AddIter_ShouldRejectTakenNames(t *testing.T) {
+	first := lizt.NewSliceIterator(nameNumbers, []string{"a"}, false)
+	mgr := lizt.NewManager().AddIter(first)
+	if err := mgr.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	mgr.AddIter(lizt.NewSliceIterator(nameNumbers, []string{"b"}, false))
+	if err := mgr.Err(); !errors.Is(err, lizt.ErrKeyExists) {
+		t.Errorf("wanted ErrKeyExists, got error = %v", err)
+	}
+	if mgr.MustGet(nameNumbers) != first {
+		t.Errorf("expected the first iterator to stay")
+	}
+
+	mgr = lizt.NewManager().AddIters(
+		lizt.NewSliceIterator("letters", []string{"a"}, false),
+		lizt.NewSliceIterator("letters", []string{"b"}, false),
+	)
+	if err := mgr.Err(); !errors.Is(err, lizt.ErrKeyExists) {
+		t.Errorf("wanted ErrKeyExists, got error = %v", err)
+	}
+	if mgr.Len() != 0 {
+		t.Errorf("expected AddIters to add none of the iterators, got %v", mgr.List())
+	}
+}
+
+func TestManager_Remove(t *testing.T) {
+	mgr := lizt.NewManager()
+	iter := lizt.NewSliceIterator(nameNumbers, []string{"a"}, false)
+	mgr.AddIter(iter)
+
+	removed, err := mgr.Remove(nameNumbers)
+	if err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if removed != iter || mgr.Len() != 0 {
+		t.Errorf("expected %s to be removed, got %v", nameNumbers, mgr.List())
+	}
+
+	if _, err = mgr.Remove(nameNumbers); !errors.Is(err, lizt.ErrKeyNotFound) {
+		t.Errorf("wanted ErrKeyNotFound, got error = %v", err)
+	}
+	if err = mgr.AddIter(iter).Err(); err != nil {
+		t.Errorf("expected the name to be free again, got error = %v", err)
+	}
+}
+
+func TestManager_Replace(t *testing.T) {
+	mgr := lizt.NewManager()
+	iter := lizt.NewSliceIterator(nameNumbers, []string{"a"}, false)
+	mgr.AddIter(iter)
+
+	replacement := lizt.NewSliceIterator(nameNumbers, []string{"b"}, false)
+	old, err := mgr.Replace(replacement)
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if old != iter || mgr.MustGet(nameNumbers) != replacement {
+		t.Errorf("expected %s to be replaced", nameNumbers)
+	}
+
+	if _, err = mgr.Replace(lizt.NewSliceIterator("letters", nil, false)); !errors.Is(err, lizt.ErrKeyNotFound) {
+		t.Errorf("wanted ErrKeyNotFound, got error = %v", err)
+	}
+}
+
+func TestManager_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "letters.txt")
+	if err := os.WriteFile(path, []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	mgr := lizt.NewManager()
+	if err := mgr.AddDirIter(dir, false); err != nil {
+		t.Fatalf("AddDirIter() error = %v", err)
+	}
+	first := mgr.MustGet("letters")
+	first.MustNext(2)
+
+	if err := os.WriteFile(path, []byte("a\nb\nc\nd\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	old, err := mgr.Reload("letters")
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if old != first {
+		t.Errorf("expected the old iterator to be returned")
+	}
+
+	expected := []string{"c", "d"}
+	if next := mgr.MustGet("letters").MustNext(2); !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected the pointer to be kept, got %v, want %v", next, expected)
+	}
+
+	if err := os.WriteFile(path, []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err = mgr.Reload("letters"); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if next := mgr.MustGet("letters").MustNextOne(); next != "x" {
+		t.Errorf("expected the pointer to be reset, got %s", next)
+	}
+}
+
+func TestManager_Reload_WithoutSource(t *testing.T) {
+	mgr := lizt.NewManager()
+	mgr.AddIter(lizt.NewSliceIterator(nameNumbers, []string{"a"}, false))
+
+	if _, err := mgr.Reload(nameNumbers); !errors.Is(err, lizt.ErrNoSource) {
+		t.Errorf("wanted ErrNoSource, got error = %v", err)
+	}
+	if _, err := mgr.Reload("letters"); !errors.Is(err, lizt.ErrKeyNotFound) {
+		t.Errorf("wanted ErrKeyNotFound, got error = %v", err)
+	}
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	mgr := lizt.NewManager()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := strconv.Itoa(i)
+			for j := 0; j < 100; j++ {
+				mgr.AddIter(lizt.NewSliceIterator(name, []string{"a"}, false))
+				_, _ = mgr.Get(name)
+				_ = mgr.List()
+				_, _ = mgr.Replace(lizt.NewSliceIterator(name, []string{"b"}, false))
+				_, _ = mgr.Remove(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if mgr.Len() != 0 {
+		t.Errorf("expected every iterator to be removed, got %v", mgr.List())
+	}
+}
+
+func TestManager_Reload_ShouldNotCloseOldIterator(t *testing.T) {
+	maxLines := lizt.MaxLinesForSliceIter
+	lizt.MaxLinesForSliceIter = 1
+	defer func() { lizt.MaxLinesForSliceIter = maxLines }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "letters.txt")
+	if err := os.WriteFile(path, []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	mgr := lizt.NewManager()
+	if err := mgr.SmartAddDirIter(dir, false); err != nil {
+		t.Fatalf("SmartAddDirIter() error = %v", err)
+	}
+	stream := mgr.MustGet("letters")
+	stream.MustNextOne()
+
+	old, err := mgr.Reload("letters")
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	defer old.(io.Closer).Close()
+
+	// a worker that still holds the old stream keeps reading it
+	if next, err := stream.Next(2); err != nil || !reflect.DeepEqual(next, []string{"b", "c"}) {
+		t.Errorf("expected %v, got %v, error = %v", []string{"b", "c"}, next, err)
+	}
+}
@@ -0,0 +1,81 @@
+package lizt
+
+import "context"
+
+// MapIterator is an iterator that transforms every line it emits. Lines map one to one, so the pointer of the
+// wrapped iterator stays valid for persistence.
+type MapIterator struct {
+	PointerIterator
+	fn func(string) string
+}
+
+// MapIteratorConfig is the config for a map iterator.
+type MapIteratorConfig struct {
+	PointerIter PointerIterator
+	Fn          func(string) string
+}
+
+// NewMapIterator returns a new map iterator.
+func NewMapIterator(cfg MapIteratorConfig) *MapIterator {
+	return &MapIterator{
+		PointerIterator: cfg.PointerIter,
+		fn:              cfg.Fn,
+	}
+}
+
+// Next returns the next lines, of a given count, from the iterator.
+func (mi *MapIterator) Next(count int) ([]string, error) {
+	return mi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, from the iterator. It stops early if ctx is done.
+func (mi *MapIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	next, err := mi.PointerIterator.NextContext(ctx, count)
+	if next == nil {
+		return nil, err
+	}
+
+	// the wrapped iterator may hand out its own backing slice, so don't map in place.
+	lines := make([]string, len(next))
+	for i, line := range next {
+		lines[i] = mi.fn(line)
+	}
+	return lines, err
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (mi *MapIterator) MustNext(count int) []string {
+	lines, err := mi.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (mi *MapIterator) NextOne() (string, error) {
+	lines, err := mi.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (mi *MapIterator) MustNextOne() string {
+	line, err := mi.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// advance moves the wrapped iterator forward to line p.
+func (mi *MapIterator) advance(p uint64) {
+	advanceTo(mi.PointerIterator, p)
+}
+
+// Unwrap returns the wrapped iterator.
+func (mi *MapIterator) Unwrap() PointerIterator {
+	return mi.PointerIterator
+}
@@ -0,0 +1,53 @@
+package lizt_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestMapIterator_Next(t *testing.T) {
+	lines := []string{"user1:pass1", "user2:pass2", "user3:pass3"}
+	mi := lizt.NewMapIterator(lizt.MapIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, lines, false),
+		Fn: func(line string) string {
+			user, _, _ := strings.Cut(line, ":")
+			return user
+		},
+	})
+
+	next, err := mi.Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"user1", "user2"}) {
+		t.Errorf("expected %v, got %v", []string{"user1", "user2"}, next)
+	}
+	if lines[0] != "user1:pass1" {
+		t.Errorf("expected the source lines to be left alone, got %s", lines[0])
+	}
+	if mi.Pointer() != 2 {
+		t.Errorf("expected pointer %d, got %d", 2, mi.Pointer())
+	}
+
+	if line := mi.MustNextOne(); line != "user3" {
+		t.Errorf("expected %s, got %s", "user3", line)
+	}
+	_, err = mi.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestMapIterator_Compose(t *testing.T) {
+	iter := lizt.B().Slice(words).Map(strings.ToUpper).Filter(longerThan(2)).Map(func(line string) string {
+		return line + "!"
+	}).MustBuild()
+
+	if next := iter.MustNext(3); !reflect.DeepEqual(next, []string{"CCC!", "EEEE!", "GGGGG!"}) {
+		t.Errorf("expected %v, got %v", []string{"CCC!", "EEEE!", "GGGGG!"}, next)
+	}
+}
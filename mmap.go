@@ -0,0 +1,188 @@
+package lizt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MmapIterator is an iterator that reads from a memory-mapped file. Lines are located through a LineIndex, so it
+// gives random access to huge files without copying the whole file into Go strings.
+type MmapIterator struct {
+	data       []byte
+	index      *LineIndex
+	pointer    *atomic.Uint64
+	cursor     int64
+	filename   string
+	name       string
+	roundRobin bool
+	mu         sync.RWMutex
+}
+
+// NewMmapIterator returns a new memory-mapped iterator. The line-offset index is built and saved next to the file
+// if it doesn't exist or is stale. Call Close to unmap the file. Compressed files can't be mapped and return
+// ErrCompressed, use a StreamIterator for those.
+func NewMmapIterator(filename string, roundRobin bool) (*MmapIterator, error) {
+	c, err := DetectCompression(filename)
+	if err != nil {
+		return nil, err
+	}
+	if c != CompressionNone {
+		return nil, fmt.Errorf("mmap: %s: %s -> %w", filename, c, ErrCompressed)
+	}
+
+	idx, err := OpenLineIndex(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := OpenFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// the mapping stays valid after the file is closed.
+	defer file.Close()
+
+	data, err := mmapFile(file, idx.size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MmapIterator{
+		data:       data,
+		index:      idx,
+		filename:   filename,
+		name:       makeNameFromFilename(filename),
+		pointer:    new(atomic.Uint64),
+		roundRobin: roundRobin,
+	}, nil
+}
+
+// Next returns the next lines, of a given count, from the iterator.
+func (mi *MmapIterator) Next(count int) ([]string, error) {
+	return mi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, from the iterator. It stops early if ctx is done.
+func (mi *MmapIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	var lines []string
+	for i := 0; i < count; i++ {
+		if err := contextErr(ctx, mi.name); err != nil {
+			return lines, err
+		}
+
+		if mi.pointer.Load() >= uint64(mi.Len()) {
+			if !mi.roundRobin || mi.Len() == 0 {
+				if len(lines) == 0 {
+					return nil, fmt.Errorf("file: %s -> %w", mi.filename, ErrNoMoreLines)
+				}
+				return lines, nil
+			}
+			mi.pointer.Store(0)
+			mi.cursor = 0
+		}
+
+		lines = append(lines, mi.readLine())
+		mi.pointer.Add(1)
+	}
+	return lines, nil
+}
+
+// readLine returns the line at the cursor and moves the cursor to the next line.
+func (mi *MmapIterator) readLine() string {
+	rest := mi.data[mi.cursor:]
+	end := bytes.IndexByte(rest, '\n')
+	if end < 0 {
+		mi.cursor = int64(len(mi.data))
+		return strings.TrimSpace(string(rest))
+	}
+	mi.cursor += int64(end + 1)
+	return strings.TrimSpace(string(rest[:end]))
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (mi *MmapIterator) MustNext(count int) []string {
+	lines, err := mi.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (mi *MmapIterator) NextOne() (string, error) {
+	lines, err := mi.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (mi *MmapIterator) MustNextOne() string {
+	line, err := mi.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// Pointer returns the current pointer.
+func (mi *MmapIterator) Pointer() uint64 {
+	return mi.pointer.Load()
+}
+
+// SetPointer sets the current pointer.
+func (mi *MmapIterator) SetPointer(p uint64) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if p > uint64(mi.Len()) {
+		p = 0
+	}
+
+	offset, skip := mi.index.Offset(p)
+	mi.cursor = offset
+	for ; skip > 0; skip-- {
+		mi.readLine()
+	}
+	mi.pointer.Store(p)
+}
+
+// Inc skips a line and increments the pointer.
+func (mi *MmapIterator) Inc() {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	mi.readLine()
+	mi.pointer.Add(1)
+}
+
+// Len returns the length of the iterator.
+func (mi *MmapIterator) Len() int {
+	return mi.index.Lines()
+}
+
+// Name returns the name of the iterator.
+func (mi *MmapIterator) Name() string {
+	return mi.name
+}
+
+// Close unmaps the file. The iterator must not be used afterwards.
+func (mi *MmapIterator) Close() error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	err := munmapFile(mi.data)
+	mi.data = nil
+	if err != nil {
+		return fmt.Errorf("munmap: %s -> %w", mi.filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package lizt
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// mmapFile reads the whole file into memory on platforms without mmap support.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, fmt.Errorf("io.ReadFull(): %s -> %w", file.Name(), err)
+	}
+	return data, nil
+}
+
+// munmapFile releases memory returned by mmapFile.
+func munmapFile(_ []byte) error {
+	return nil
+}
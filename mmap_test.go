@@ -0,0 +1,129 @@
+package lizt_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestMmapIterator_Next(t *testing.T) {
+	mi, err := lizt.NewMmapIterator(copyToTemp(t, filenameTen), false)
+	if err != nil {
+		t.Fatalf("NewMmapIterator() error = %v", err)
+	}
+	defer mi.Close()
+
+	if mi.Len() != 10 {
+		t.Errorf("expected %d lines, got %d", 10, mi.Len())
+	}
+
+	first, err := mi.Next(5)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(first, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b", "c", "d", "e"}, first)
+	}
+
+	second, err := mi.Next(10)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(second, []string{"f", "g", "h", "i", "j"}) {
+		t.Errorf("expected %v, got %v", []string{"f", "g", "h", "i", "j"}, second)
+	}
+
+	_, err = mi.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestMmapIterator_Next_RoundRobin(t *testing.T) {
+	mi, err := lizt.NewMmapIterator(copyToTemp(t, filenameTen), true)
+	if err != nil {
+		t.Fatalf("NewMmapIterator() error = %v", err)
+	}
+	defer mi.Close()
+
+	first, err := mi.Next(10)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	second, err := mi.Next(10)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected %v to be %v", first, second)
+	}
+
+	var expected uint64 = 10
+	if mi.Pointer() != expected {
+		t.Errorf("expected pointer to be %d, got %d", expected, mi.Pointer())
+	}
+}
+
+func TestMmapIterator_SetPointer(t *testing.T) {
+	mi, err := lizt.NewMmapIterator(copyToTemp(t, filenameTen), true)
+	if err != nil {
+		t.Fatalf("NewMmapIterator() error = %v", err)
+	}
+	defer mi.Close()
+
+	mi.SetPointer(8)
+	next, err := mi.Next(3)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+
+	expected := []string{"i", "j", "a"}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+}
+
+func TestManager_SmartAddDirIter_Mmap(t *testing.T) {
+	path := copyToTemp(t, filenameTen)
+
+	maxLines, largeFileIter := lizt.MaxLinesForSliceIter, lizt.SmartLargeFileIterator
+	lizt.MaxLinesForSliceIter, lizt.SmartLargeFileIterator = 5, lizt.LargeFileMmap
+	defer func() {
+		lizt.MaxLinesForSliceIter, lizt.SmartLargeFileIterator = maxLines, largeFileIter
+	}()
+
+	mgr := lizt.NewManager()
+	if err := mgr.SmartAddDirIter(filepath.Dir(path), false); err != nil {
+		t.Fatalf("SmartAddDirIter() error = %v", err)
+	}
+
+	tenIter, err := mgr.Get("10")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reflect.TypeOf(tenIter).Elem().Name() != "MmapIterator" {
+		t.Errorf("expected MmapIterator, got %s", reflect.TypeOf(tenIter).Elem().Name())
+	}
+	_ = tenIter.(*lizt.MmapIterator).Close()
+}
+
+func TestNewIteratorBuilder_Mmap_Build(t *testing.T) {
+	si, err := lizt.B().MmapRR(copyToTemp(t, filenameTen)).Build()
+	if err != nil {
+		t.Errorf("Builder() error = %v", err)
+	}
+
+	next, err := si.Next(12)
+	if err != nil {
+		t.Errorf("Builder.Next() error = %v", err)
+	}
+
+	expected := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "a", "b"}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("Builder.Next() = %v, want %v", next, expected)
+	}
+}
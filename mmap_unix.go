@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package lizt
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file read-only into memory.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("syscall.Mmap(): %s -> %w", file.Name(), err)
+	}
+	return data, nil
+}
+
+// munmapFile releases memory returned by mmapFile.
+func munmapFile(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
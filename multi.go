@@ -0,0 +1,326 @@
+package lizt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrNoIterators = errors.New("no iterators")
+
+// MultiStrategy decides which child a MultiIterator draws the next line from.
+type MultiStrategy int
+
+const (
+	// MultiSequential drains the children one after another, as if they were concatenated.
+	MultiSequential MultiStrategy = iota
+	// MultiRoundRobin takes one line from each child in turn, skipping children that are exhausted.
+	MultiRoundRobin
+	// MultiWeighted picks a child at random, proportional to its weight, skipping children that are exhausted.
+	MultiWeighted
+)
+
+// MultiIterator is an iterator that draws from several iterators as one source. Its pointer is the number of lines
+// drawn in the current cycle, and SetPointer derives every child pointer from it, so a PersistentIterator only has
+// to persist the combined pointer.
+type MultiIterator struct {
+	name       string
+	iters      []PointerIterator
+	strategy   MultiStrategy
+	weights    []uint64
+	seed       uint64
+	lens       []uint64
+	counts     []uint64
+	total      uint64
+	pointer    uint64
+	turn       int
+	roundRobin bool
+	mu         sync.Mutex
+}
+
+// MultiIteratorConfig is the config for a multi iterator. Weights are only used by MultiWeighted and default to 1
+// per iterator. The Seed makes the weighted order reproducible.
+type MultiIteratorConfig struct {
+	Name       string
+	Iters      []PointerIterator
+	Strategy   MultiStrategy
+	Weights    []int
+	Seed       int64
+	RoundRobin bool
+}
+
+// NewMultiIterator returns a new multi iterator. Every child pointer is reset, and the lengths of the children are
+// fixed at creation.
+func NewMultiIterator(cfg MultiIteratorConfig) (*MultiIterator, error) {
+	if len(cfg.Iters) == 0 {
+		return nil, fmt.Errorf("multi: name: %s -> %w", cfg.Name, ErrNoIterators)
+	}
+	if cfg.Weights != nil && len(cfg.Weights) != len(cfg.Iters) {
+		return nil, fmt.Errorf("multi: name: %s: %d weights for %d iterators", cfg.Name, len(cfg.Weights), len(cfg.Iters))
+	}
+
+	mi := &MultiIterator{
+		name:       cfg.Name,
+		iters:      cfg.Iters,
+		strategy:   cfg.Strategy,
+		seed:       uint64(cfg.Seed),
+		weights:    make([]uint64, len(cfg.Iters)),
+		lens:       make([]uint64, len(cfg.Iters)),
+		counts:     make([]uint64, len(cfg.Iters)),
+		roundRobin: cfg.RoundRobin,
+	}
+	for i, iter := range cfg.Iters {
+		mi.weights[i] = 1
+		if cfg.Weights != nil {
+			if cfg.Weights[i] < 1 {
+				return nil, fmt.Errorf("multi: name: %s: weight %d of %s must be positive", cfg.Name, cfg.Weights[i], iter.Name())
+			}
+			mi.weights[i] = uint64(cfg.Weights[i])
+		}
+		mi.lens[i] = uint64(iter.Len())
+		mi.total += mi.lens[i]
+	}
+
+	mi.setPointer(0)
+	return mi, nil
+}
+
+// Next returns the next lines, of a given count, from the iterator.
+func (mi *MultiIterator) Next(count int) ([]string, error) {
+	return mi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, from the iterator. It stops early if ctx is done.
+func (mi *MultiIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	var lines []string
+	for i := 0; i < count; i++ {
+		if err := contextErr(ctx, mi.name); err != nil {
+			return lines, err
+		}
+
+		if mi.pointer >= mi.total {
+			if !mi.roundRobin || mi.total == 0 {
+				if len(lines) == 0 {
+					return nil, fmt.Errorf("multi: name: %s -> %w", mi.name, ErrNoMoreLines)
+				}
+				return lines, nil
+			}
+			mi.setPointer(0)
+		}
+
+		line, err := mi.draw(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return lines, contextErr(ctx, mi.name)
+			}
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// draw reads one line from the child picked by the strategy.
+func (mi *MultiIterator) draw(ctx context.Context) (string, error) {
+	i := mi.pick()
+	next, err := mi.iters[i].NextContext(ctx, 1)
+	if err != nil {
+		return "", fmt.Errorf("multi: name: %s: child: %s -> %w", mi.name, mi.iters[i].Name(), err)
+	}
+
+	mi.advance(i)
+	return next[0], nil
+}
+
+// pick returns the child the next line comes from. There must be lines left in the cycle.
+func (mi *MultiIterator) pick() int {
+	switch mi.strategy {
+	case MultiRoundRobin:
+		for i := 0; i < len(mi.iters); i++ {
+			c := (mi.turn + i) % len(mi.iters)
+			if mi.counts[c] < mi.lens[c] {
+				return c
+			}
+		}
+	case MultiWeighted:
+		var sum uint64
+		for c := range mi.iters {
+			if mi.counts[c] < mi.lens[c] {
+				sum += mi.weights[c]
+			}
+		}
+		// the choice only depends on the seed, the pointer and which children are exhausted, so it can be replayed.
+		u := mix64(mi.seed^mix64(mi.pointer)) % sum
+		for c := range mi.iters {
+			if mi.counts[c] >= mi.lens[c] {
+				continue
+			}
+			if u < mi.weights[c] {
+				return c
+			}
+			u -= mi.weights[c]
+		}
+	}
+
+	for c := range mi.iters {
+		if mi.counts[c] < mi.lens[c] {
+			return c
+		}
+	}
+	return 0
+}
+
+// advance records a line drawn from child c.
+func (mi *MultiIterator) advance(c int) {
+	mi.counts[c]++
+	mi.pointer++
+	mi.turn = (c + 1) % len(mi.iters)
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (mi *MultiIterator) MustNext(count int) []string {
+	lines, err := mi.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (mi *MultiIterator) NextOne() (string, error) {
+	lines, err := mi.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (mi *MultiIterator) MustNextOne() string {
+	line, err := mi.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// Pointer returns the combined pointer.
+func (mi *MultiIterator) Pointer() uint64 {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	return mi.pointer
+}
+
+// SetPointer sets the combined pointer and moves every child to the line it would be at. This is cheap for the
+// sequential and round-robin strategies. The weighted strategy replays its choices, which takes O(p).
+func (mi *MultiIterator) SetPointer(p uint64) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if p > mi.total {
+		p = 0
+	}
+	mi.setPointer(p)
+}
+
+func (mi *MultiIterator) setPointer(p uint64) {
+	for c := range mi.counts {
+		mi.counts[c] = 0
+	}
+	mi.pointer = 0
+	mi.turn = 0
+
+	switch mi.strategy {
+	case MultiSequential:
+		for c := range mi.iters {
+			n := mi.lens[c]
+			if p < n {
+				n = p
+			}
+			mi.counts[c] = n
+			p -= n
+		}
+	case MultiRoundRobin:
+		mi.setRoundRobinCounts(p)
+	case MultiWeighted:
+		for i := uint64(0); i < p; i++ {
+			mi.advance(mi.pick())
+		}
+	}
+
+	mi.pointer = 0
+	for c, iter := range mi.iters {
+		iter.SetPointer(mi.counts[c])
+		mi.pointer += mi.counts[c]
+	}
+}
+
+// setRoundRobinCounts computes the counts after p round-robin draws one round of the shortest child at a time.
+func (mi *MultiIterator) setRoundRobinCounts(p uint64) {
+	for p > 0 {
+		var active []int
+		var least uint64
+		for c := range mi.iters {
+			left := mi.lens[c] - mi.counts[c]
+			if left == 0 {
+				continue
+			}
+			if len(active) == 0 || left < least {
+				least = left
+			}
+			active = append(active, c)
+		}
+		if len(active) == 0 {
+			return
+		}
+
+		k := uint64(len(active))
+		if p >= least*k {
+			for _, c := range active {
+				mi.counts[c] += least
+			}
+			p -= least * k
+			continue
+		}
+
+		q, r := p/k, p%k
+		for i, c := range active {
+			mi.counts[c] += q
+			if uint64(i) < r {
+				mi.counts[c]++
+				mi.turn = (c + 1) % len(mi.iters)
+			}
+		}
+		return
+	}
+}
+
+// Inc skips a line.
+func (mi *MultiIterator) Inc() {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if mi.pointer < mi.total {
+		_, _ = mi.draw(context.Background())
+	}
+}
+
+// Len returns the combined length of the children.
+func (mi *MultiIterator) Len() int {
+	return int(mi.total)
+}
+
+// Name returns the name of the iterator.
+func (mi *MultiIterator) Name() string {
+	return mi.name
+}
+
+// Iters returns the children of the iterator.
+func (mi *MultiIterator) Iters() []PointerIterator {
+	return mi.iters
+}
@@ -0,0 +1,181 @@
+package lizt_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestMultiIterator_Next_Sequential(t *testing.T) {
+	mi, err := lizt.NewMultiIterator(lizt.MultiIteratorConfig{
+		Name: "multi",
+		Iters: []lizt.PointerIterator{
+			lizt.NewSliceIterator("letters", []string{"a", "b", "c", "d"}, false),
+			lizt.NewSliceIterator("numbers", []string{"1", "2"}, false),
+			lizt.NewSliceIterator("symbols", []string{"!", "@", "#"}, false),
+		},
+		Strategy: lizt.MultiSequential,
+	})
+	if err != nil {
+		t.Fatalf("NewMultiIterator() error = %v", err)
+	}
+
+	next, err := mi.Next(10)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+
+	expected := []string{"a", "b", "c", "d", "1", "2", "!", "@", "#"}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+
+	_, err = mi.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestMultiIterator_Next_RoundRobin(t *testing.T) {
+	mi, err := lizt.NewMultiIterator(lizt.MultiIteratorConfig{
+		Name: "multi",
+		Iters: []lizt.PointerIterator{
+			lizt.NewSliceIterator("letters", []string{"a", "b", "c", "d"}, false),
+			lizt.NewSliceIterator("numbers", []string{"1", "2"}, false),
+			lizt.NewSliceIterator("symbols", []string{"!", "@", "#"}, false),
+		},
+		Strategy:   lizt.MultiRoundRobin,
+		RoundRobin: true,
+	})
+	if err != nil {
+		t.Fatalf("NewMultiIterator() error = %v", err)
+	}
+
+	next, err := mi.Next(11)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+
+	expected := []string{"a", "1", "!", "b", "2", "@", "c", "#", "d", "a", "1"}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+
+	var pointer uint64 = 2
+	if mi.Pointer() != pointer {
+		t.Errorf("expected pointer to be %d, got %d", pointer, mi.Pointer())
+	}
+}
+
+func TestMultiIterator_Next_Weighted(t *testing.T) {
+	weighted := func() *lizt.MultiIterator {
+		mi, err := lizt.NewMultiIterator(lizt.MultiIteratorConfig{
+			Name: "multi",
+			Iters: []lizt.PointerIterator{
+				lizt.NewSliceIterator("letters", []string{"a", "b", "c", "d"}, false),
+				lizt.NewSliceIterator("numbers", []string{"1", "2"}, false),
+				lizt.NewSliceIterator("symbols", []string{"!", "@", "#"}, false),
+			},
+			Strategy: lizt.MultiWeighted,
+			Weights:  []int{5, 1, 2},
+			Seed:     7,
+		})
+		if err != nil {
+			t.Fatalf("NewMultiIterator() error = %v", err)
+		}
+		return mi
+	}
+	mi := weighted()
+
+	next, err := mi.Next(9)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+
+	sort.Strings(next)
+	expected := []string{"!", "#", "1", "2", "@", "a", "b", "c", "d"}
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected every line exactly once, got %v", next)
+	}
+
+	first := weighted().MustNext(9)
+	second := weighted().MustNext(9)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same seed to give the same order")
+	}
+}
+
+func TestMultiIterator_SetPointer_ShouldRestoreEveryStrategy(t *testing.T) {
+	for _, strategy := range []lizt.MultiStrategy{lizt.MultiSequential, lizt.MultiRoundRobin, lizt.MultiWeighted} {
+		multi := func() *lizt.MultiIterator {
+			mi, err := lizt.NewMultiIterator(lizt.MultiIteratorConfig{
+				Name: "multi",
+				Iters: []lizt.PointerIterator{
+					lizt.NewSliceIterator("letters", []string{"a", "b", "c", "d"}, false),
+					lizt.NewSliceIterator("numbers", []string{"1", "2"}, false),
+					lizt.NewSliceIterator("symbols", []string{"!", "@", "#"}, false),
+				},
+				Strategy:   strategy,
+				Weights:    []int{5, 1, 2},
+				Seed:       7,
+				RoundRobin: true,
+			})
+			if err != nil {
+				t.Fatalf("NewMultiIterator() error = %v", err)
+			}
+			return mi
+		}
+
+		for p := 0; p <= 9; p++ {
+			reference := multi()
+			reference.MustNext(p)
+			expected := reference.MustNext(5)
+
+			restored := multi()
+			restored.SetPointer(uint64(p))
+			if next := restored.MustNext(5); !reflect.DeepEqual(next, expected) {
+				t.Errorf("strategy %d, pointer %d: expected %v, got %v", strategy, p, expected, next)
+			}
+		}
+	}
+}
+
+func TestMultiIterator_Persistent(t *testing.T) {
+	mem := NewInMemoryPersister()
+	mem.pointers["multi"] = 4
+
+	mi, err := lizt.NewMultiIterator(lizt.MultiIteratorConfig{
+		Name: "multi",
+		Iters: []lizt.PointerIterator{
+			lizt.NewSliceIterator("letters", []string{"a", "b", "c", "d"}, false),
+			lizt.NewSliceIterator("numbers", []string{"1", "2"}, false),
+			lizt.NewSliceIterator("symbols", []string{"!", "@", "#"}, false),
+		},
+		Strategy: lizt.MultiRoundRobin,
+	})
+	if err != nil {
+		t.Fatalf("NewMultiIterator() error = %v", err)
+	}
+
+	p, err := lizt.NewPersistentIterator(lizt.PersistentIteratorConfig{
+		PointerIter: mi,
+		Persister:   mem,
+	})
+	if err != nil {
+		t.Fatalf("NewPersistentIterator() error = %v", err)
+	}
+
+	next, err := p.Next(3)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"2", "@", "c"}) {
+		t.Errorf("expected %v, got %v", []string{"2", "@", "c"}, next)
+	}
+	if mem.pointers["multi"] != 7 {
+		t.Errorf("expected %d, got %d", 7, mem.pointers["multi"])
+	}
+}
@@ -0,0 +1,75 @@
+package lizt
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer rewrites a line into its canonical form, so that lines which only differ in e.g. case or encoding
+// compare equal.
+type Normalizer func(string) string
+
+// ChainNormalizers returns a normalizer that applies every normalizer in order.
+func ChainNormalizers(ns ...Normalizer) Normalizer {
+	return func(line string) string {
+		for _, n := range ns {
+			line = n(line)
+		}
+		return line
+	}
+}
+
+// NormalizeTrimSpace strips leading and trailing white space.
+func NormalizeTrimSpace(line string) string {
+	return strings.TrimSpace(line)
+}
+
+// NormalizeFoldCase folds the case of a line, which is the Unicode-aware way of comparing case-insensitively.
+func NormalizeFoldCase(line string) string {
+	return cases.Fold().String(line)
+}
+
+// NormalizeNFC puts a line into Unicode normalization form C, so composed and decomposed accents compare equal.
+func NormalizeNFC(line string) string {
+	return norm.NFC.String(line)
+}
+
+// NormalizeStripPort strips the port from "host:port" and "[ipv6]:port" lines.
+func NormalizeStripPort(line string) string {
+	if host, _, err := net.SplitHostPort(line); err == nil {
+		return host
+	}
+	return line
+}
+
+// NormalizeStripQuery strips the query string and fragment from a URL.
+func NormalizeStripQuery(line string) string {
+	if i := strings.IndexAny(line, "?#"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// NormalizingIterator is an iterator that emits normalized lines. It's a MapIterator with a Normalizer.
+type NormalizingIterator struct {
+	*MapIterator
+}
+
+// NormalizingIteratorConfig is the config for a normalizing iterator.
+type NormalizingIteratorConfig struct {
+	PointerIter PointerIterator
+	Normalizer  Normalizer
+}
+
+// NewNormalizingIterator returns a new normalizing iterator.
+func NewNormalizingIterator(cfg NormalizingIteratorConfig) *NormalizingIterator {
+	return &NormalizingIterator{
+		MapIterator: NewMapIterator(MapIteratorConfig{
+			PointerIter: cfg.PointerIter,
+			Fn:          cfg.Normalizer,
+		}),
+	}
+}
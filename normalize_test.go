@@ -0,0 +1,101 @@
+package lizt_test
+
+import (
+	"reflect"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		normalizer lizt.Normalizer
+		line       string
+		expected   string
+	}{
+		{lizt.NormalizeTrimSpace, "  a b \t", "a b"},
+		{lizt.NormalizeFoldCase, "User@Example.COM", "user@example.com"},
+		{lizt.NormalizeFoldCase, "STRASSE", "strasse"},
+		{lizt.NormalizeNFC, "cafe\u0301", "caf\u00e9"},
+		{lizt.NormalizeStripPort, "example.com:8080", "example.com"},
+		{lizt.NormalizeStripPort, "[::1]:443", "::1"},
+		{lizt.NormalizeStripPort, "example.com", "example.com"},
+		{lizt.NormalizeStripQuery, "https://example.com/a?b=c#d", "https://example.com/a"},
+		{lizt.NormalizeStripQuery, "https://example.com/a#d", "https://example.com/a"},
+		{lizt.ChainNormalizers(lizt.NormalizeTrimSpace, lizt.NormalizeFoldCase), " A ", "a"},
+	}
+	for _, tt := range tests {
+		if got := tt.normalizer(tt.line); got != tt.expected {
+			t.Errorf("normalize(%q) = %q, want %q", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestBlacklistManager_Normalize(t *testing.T) {
+	blm := lizt.NewBlacklistManager(lizt.BlacklistMap{"User@Example.com": {}}).Normalize(lizt.NormalizeFoldCase)
+
+	if !blm.Has("user@example.com") || !blm.Has("USER@EXAMPLE.COM") {
+		t.Errorf("expected every casing to be blacklisted")
+	}
+	if err := blm.Add("USER@example.com"); err == nil {
+		t.Errorf("expected an error adding a normalized duplicate")
+	}
+	if err := blm.Remove("user@EXAMPLE.com"); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if blm.Len() != 0 {
+		t.Errorf("expected %d, got %d", 0, blm.Len())
+	}
+}
+
+func TestBlacklister_Next_Normalized(t *testing.T) {
+	emails := []string{"User@Example.com", "other@example.com", "ADMIN@example.com"}
+	blm := lizt.NewBlacklistManager(lizt.BlacklistMap{"user@example.com": {}, "Admin@Example.com": {}}).Normalize(lizt.NormalizeFoldCase)
+
+	// the manager normalizes the check, the line is emitted as is.
+	next, err := lizt.B().Slice(emails).Blacklist(blm).MustBuild().Next(1)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"other@example.com"}) {
+		t.Errorf("expected %v, got %v", []string{"other@example.com"}, next)
+	}
+
+	// the iterator normalizes the check for a backend that doesn't normalize itself.
+	bi, _ := lizt.NewBlacklistingIterator(lizt.BlacklistingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, emails, false),
+		Blacklisted: lizt.BlacklistMap{"user@example.com": {}},
+		Normalizer:  lizt.NormalizeFoldCase,
+	})
+	if next = bi.MustNext(2); !reflect.DeepEqual(next, []string{"other@example.com", "ADMIN@example.com"}) {
+		t.Errorf("expected %v, got %v", []string{"other@example.com", "ADMIN@example.com"}, next)
+	}
+
+	// Normalize normalizes the emitted lines too.
+	lines := []string{" Cafe\u0301 ", "tea"}
+	next, err = lizt.B().Slice(lines).Normalize(lizt.NormalizeTrimSpace, lizt.NormalizeNFC, lizt.NormalizeFoldCase).MustBuild().Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"caf\u00e9", "tea"}) {
+		t.Errorf("expected %v, got %v", []string{"caf\u00e9", "tea"}, next)
+	}
+	if lines[0] != " Cafe\u0301 " {
+		t.Errorf("expected the source lines to be left alone, got %q", lines[0])
+	}
+}
+
+func TestNormalizingIterator_Next(t *testing.T) {
+	ni := lizt.NewNormalizingIterator(lizt.NormalizingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"A:80", "b"}, false),
+		Normalizer:  lizt.ChainNormalizers(lizt.NormalizeStripPort, lizt.NormalizeFoldCase),
+	})
+
+	expected := []string{"a", "b"}
+	if next := ni.MustNext(2); !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+	if ni.Pointer() != 2 {
+		t.Errorf("expected pointer to be %d, got %d", 2, ni.Pointer())
+	}
+}
@@ -1,11 +1,20 @@
 package lizt
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
 
-// PersistentIterator is an iterator that persists the pointer.
+// PersistentIterator is an iterator that persists the pointer. The state of any Stateful iterator it wraps is
+// persisted alongside the pointer.
 type PersistentIterator struct {
 	Persister
 	PointerIterator
+	stateful []Stateful
+	saved    map[string]uint64
+	mu       sync.Mutex
 }
 
 // PersistentIteratorConfig is the config for a persistent iterator.
@@ -14,29 +23,136 @@ type PersistentIteratorConfig struct {
 	Persister   Persister
 }
 
-// NewPersistentIterator returns a new persistent iterator. It will set the pointer to the last known pointer.
+// NewPersistentIterator returns a new persistent iterator. It will set the pointer to the last known pointer. If the
+// persister reports ErrCorruptState, an error is returned instead of starting the list over.
 func NewPersistentIterator(cfg PersistentIteratorConfig) (*PersistentIterator, error) {
-	if val, err := cfg.Persister.Get(cfg.PointerIter.Name()); err == nil {
+	name := cfg.PointerIter.Name()
+	val, err := cfg.Persister.Get(name)
+	if errors.Is(err, ErrCorruptState) {
+		return nil, fmt.Errorf("persist: name: %s -> %w", name, err)
+	}
+	if err == nil {
 		cfg.PointerIter.SetPointer(val)
 	}
 
-	return &PersistentIterator{
+	pi := &PersistentIterator{
 		PointerIterator: cfg.PointerIter,
 		Persister:       cfg.Persister,
-	}, nil
+		stateful:        statefulLayers(cfg.PointerIter),
+		saved:           make(map[string]uint64),
+	}
+
+	for _, layer := range pi.stateful {
+		restored := make(map[string]uint64)
+		for key := range layer.State() {
+			val, err := cfg.Persister.Get(stateKey(name, key))
+			if errors.Is(err, ErrCorruptState) {
+				return nil, fmt.Errorf("persist: name: %s -> %w", name, err)
+			}
+			if err == nil {
+				restored[key] = val
+				pi.saved[stateKey(name, key)] = val
+			}
+		}
+		if len(restored) > 0 {
+			layer.SetState(restored)
+		}
+	}
+
+	return pi, nil
+}
+
+// statefulLayers returns every Stateful iterator in the chain of wrapped iterators.
+func statefulLayers(iter PointerIterator) []Stateful {
+	var layers []Stateful
+	for iter != nil {
+		if s, ok := iter.(Stateful); ok {
+			layers = append(layers, s)
+		}
+		u, ok := iter.(Unwrapper)
+		if !ok {
+			break
+		}
+		iter = u.Unwrap()
+	}
+	return layers
+}
+
+// stateKey returns the persister key of a state key.
+func stateKey(name, key string) string {
+	return name + "." + key
+}
+
+// save persists the pointer and the state keys that changed since they were last saved. With a TxPersister they're
+// written in one transaction.
+func (pi *PersistentIterator) save() error {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	changed := make(map[string]uint64)
+	for _, layer := range pi.stateful {
+		for key, val := range layer.State() {
+			k := stateKey(pi.Name(), key)
+			if saved, ok := pi.saved[k]; ok && saved == val {
+				continue
+			}
+			changed[k] = val
+		}
+	}
+
+	err := updatePersister(pi.Persister, func(p Persister) error {
+		if err := p.Set(pi.Name(), pi.Pointer()); err != nil {
+			return err
+		}
+		for k, val := range changed {
+			if err := p.Set(k, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, val := range changed {
+		pi.saved[k] = val
+	}
+	return nil
+}
+
+// updatePersister runs fn in a transaction if p is a TxPersister, or else directly on p.
+func updatePersister(p Persister, fn func(Persister) error) error {
+	if tx, ok := p.(TxPersister); ok {
+		return tx.Update(fn)
+	}
+	return fn(p)
+}
+
+// Unwrap returns the wrapped iterator.
+func (pi *PersistentIterator) Unwrap() PointerIterator {
+	return pi.PointerIterator
 }
 
 // Next returns the next line from the iterator.
 func (pi *PersistentIterator) Next(count int) ([]string, error) {
-	next, err := pi.PointerIterator.Next(count)
-	if err != nil {
-		return nil, fmt.Errorf("next: name: %s -> %w", pi.Name(), err)
+	return pi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines from the iterator and persists the pointer. It stops early if ctx is done,
+// in which case the pointer of any lines already consumed is still persisted.
+func (pi *PersistentIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	next, nextErr := pi.PointerIterator.NextContext(ctx, count)
+	if nextErr != nil && len(next) == 0 {
+		return nil, fmt.Errorf("next: name: %s -> %w", pi.Name(), nextErr)
 	}
 
-	err = pi.Set(pi.Name(), pi.Pointer())
-	if err != nil {
+	if err := pi.save(); err != nil {
 		return nil, err
 	}
+	if nextErr != nil {
+		return next, fmt.Errorf("next: name: %s -> %w", pi.Name(), nextErr)
+	}
 	return next, nil
 }
 
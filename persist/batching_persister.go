@@ -0,0 +1,224 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+var (
+	ErrNoPersister = errors.New("no persister")
+	ErrNoDeleter   = errors.New("persister can't delete keys")
+)
+
+// BatchingPersister is a write-behind persister. Set only records the value in memory and the latest value of every
+// key is written to the wrapped persister in one batch: every Interval, after MaxPending calls to Set, and on Flush
+// or Close.
+//
+// Values that aren't flushed yet are lost on a crash, so a PersistentIterator resumes from an older pointer and
+// replays the lines it returned since. At most MaxPending-1 calls to Set, and at most Interval worth of calls, go
+// unflushed. A PersistentIterator calls Set once per Next (plus once per changed state key), so with MaxPending 100
+// and Next(10) a crash replays at most 990 lines. Call Close before exiting.
+type BatchingPersister struct {
+	persister  lizt.Persister
+	maxPending int
+	onError    func(error)
+	pending    map[string]uint64
+	inflight   map[string]uint64
+	sets       int
+	mu         sync.Mutex
+	flushMu    sync.Mutex
+	stop       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// BatchingPersisterConfig is the config for a batching persister. Interval and MaxPending are disabled when zero,
+// in which case the values are only written on Flush or Close. OnError receives the errors of interval flushes.
+type BatchingPersisterConfig struct {
+	Persister  lizt.Persister
+	Interval   time.Duration
+	MaxPending int
+	OnError    func(error)
+}
+
+// NewBatchingPersister returns a new batching persister. With an Interval, it flushes in the background until it's
+// closed.
+func NewBatchingPersister(cfg BatchingPersisterConfig) (*BatchingPersister, error) {
+	if cfg.Persister == nil {
+		return nil, fmt.Errorf("batching persister -> %w", ErrNoPersister)
+	}
+
+	bp := &BatchingPersister{
+		persister:  cfg.Persister,
+		maxPending: cfg.MaxPending,
+		onError:    cfg.OnError,
+		pending:    make(map[string]uint64),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	if cfg.Interval <= 0 {
+		close(bp.done)
+		return bp, nil
+	}
+
+	go func() {
+		defer close(bp.done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-bp.stop:
+				return
+			case <-ticker.C:
+				if err := bp.Flush(); err != nil && bp.onError != nil {
+					bp.onError(err)
+				}
+			}
+		}
+	}()
+	return bp, nil
+}
+
+// Set records the value of a key. It flushes once MaxPending values are waiting.
+func (bp *BatchingPersister) Set(key string, value uint64) error {
+	bp.mu.Lock()
+	bp.pending[key] = value
+	bp.sets++
+	full := bp.maxPending > 0 && bp.sets >= bp.maxPending
+	bp.mu.Unlock()
+
+	if full {
+		return bp.Flush()
+	}
+	return nil
+}
+
+// Get gets the value of a key, including values that aren't flushed yet or are being flushed.
+func (bp *BatchingPersister) Get(key string) (uint64, error) {
+	bp.mu.Lock()
+	val, ok := bp.pending[key]
+	if !ok {
+		val, ok = bp.inflight[key]
+	}
+	bp.mu.Unlock()
+
+	if ok {
+		return val, nil
+	}
+	return bp.persister.Get(key)
+}
+
+// Increment flushes the pending values and increments the key in the wrapped persister, so it's as atomic as the
+// wrapped persister's Increment.
+func (bp *BatchingPersister) Increment(key string, delta uint64) (uint64, error) {
+	if err := bp.Flush(); err != nil {
+		return 0, err
+	}
+	return bp.persister.Increment(key, delta)
+}
+
+// CompareAndSwap flushes the pending values and swaps the key in the wrapped persister, so it's as atomic as the
+// wrapped persister's CompareAndSwap.
+func (bp *BatchingPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	if err := bp.Flush(); err != nil {
+		return false, err
+	}
+	return bp.persister.CompareAndSwap(key, old, new)
+}
+
+// Delete flushes the pending values and deletes the key from the wrapped persister, which has to be a lizt.Deleter.
+func (bp *BatchingPersister) Delete(key string) error {
+	d, ok := bp.persister.(lizt.Deleter)
+	if !ok {
+		return fmt.Errorf("batching persister: delete: %s -> %w", key, ErrNoDeleter)
+	}
+	if err := bp.Flush(); err != nil {
+		return err
+	}
+	return d.Delete(key)
+}
+
+// Pending returns the number of calls to Set since the last flush.
+func (bp *BatchingPersister) Pending() int {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	return bp.sets
+}
+
+// Flush writes the pending values to the wrapped persister, in one transaction if it's a lizt.TxPersister. Get keeps
+// reading the batch until the write returns. Values that fail to write stay pending, unless Set was called with a
+// newer value in the meantime.
+func (bp *BatchingPersister) Flush() error {
+	bp.flushMu.Lock()
+	defer bp.flushMu.Unlock()
+
+	bp.mu.Lock()
+	batch := bp.pending
+	bp.inflight = batch
+	bp.pending = make(map[string]uint64)
+	bp.sets = 0
+	bp.mu.Unlock()
+
+	failed, err := bp.write(batch)
+
+	bp.mu.Lock()
+	for key, val := range failed {
+		if _, ok := bp.pending[key]; !ok {
+			bp.pending[key] = val
+			bp.sets++
+		}
+	}
+	bp.inflight = nil
+	bp.mu.Unlock()
+	return err
+}
+
+// write writes the batch to the wrapped persister and returns the values that failed. A lizt.TxPersister writes the
+// whole batch in one transaction, e.g. one rewrite of a file, so either every value fails or none does.
+func (bp *BatchingPersister) write(batch map[string]uint64) (map[string]uint64, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	if tx, ok := bp.persister.(lizt.TxPersister); ok {
+		err := tx.Update(func(p lizt.Persister) error {
+			for key, val := range batch {
+				if err := p.Set(key, val); err != nil {
+					return fmt.Errorf("flush: key: %s -> %w", key, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return batch, err
+		}
+		return nil, nil
+	}
+
+	var err error
+	failed := make(map[string]uint64)
+	for key, val := range batch {
+		if setErr := bp.persister.Set(key, val); setErr != nil {
+			failed[key] = val
+			if err == nil {
+				err = fmt.Errorf("flush: key: %s -> %w", key, setErr)
+			}
+		}
+	}
+	return failed, err
+}
+
+// Close stops the background flushes and flushes the pending values.
+func (bp *BatchingPersister) Close() error {
+	bp.closeOnce.Do(func() {
+		close(bp.stop)
+	})
+	<-bp.done
+	return bp.Flush()
+}
@@ -0,0 +1,385 @@
+package persist
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+var errWrite = errors.New("write failed")
+
+// countingPersister is an in-memory persister that counts writes and can be made to fail.
+type countingPersister struct {
+	values map[string]uint64
+	writes int
+	fail   bool
+	mu     sync.Mutex
+}
+
+func newCountingPersister() *countingPersister {
+	return &countingPersister{values: make(map[string]uint64)}
+}
+
+func (c *countingPersister) Set(key string, value uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fail {
+		return errWrite
+	}
+	c.values[key] = value
+	c.writes++
+	return nil
+}
+
+func (c *countingPersister) Get(key string) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	val, ok := c.values[key]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return val, nil
+}
+
+func (c *countingPersister) Increment(key string, delta uint64) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fail {
+		return 0, errWrite
+	}
+	c.values[key] += delta
+	c.writes++
+	return c.values[key], nil
+}
+
+func (c *countingPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fail {
+		return false, errWrite
+	}
+	if c.values[key] != old {
+		return false, nil
+	}
+	c.values[key] = new
+	c.writes++
+	return true, nil
+}
+
+func (c *countingPersister) Writes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.writes
+}
+
+func TestBatchingPersister_Coalesces(t *testing.T) {
+	under := newCountingPersister()
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	for i := uint64(1); i <= 10; i++ {
+		_ = bp.Set("list", i)
+		_ = bp.Set("list.shuffle.seed", 42)
+	}
+	if under.Writes() != 0 {
+		t.Errorf("expected no writes before a flush, got %d", under.Writes())
+	}
+	if got, _ := bp.Get("list"); got != 10 {
+		t.Errorf("expected %d, got %d", 10, got)
+	}
+	if bp.Pending() != 20 {
+		t.Errorf("expected %d pending, got %d", 20, bp.Pending())
+	}
+
+	if err := bp.Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if under.Writes() != 2 {
+		t.Errorf("expected %d writes, got %d", 2, under.Writes())
+	}
+	if got, _ := under.Get("list"); got != 10 {
+		t.Errorf("expected %d, got %d", 10, got)
+	}
+	if bp.Pending() != 0 {
+		t.Errorf("expected nothing pending, got %d", bp.Pending())
+	}
+}
+
+func TestBatchingPersister_MaxPending(t *testing.T) {
+	under := newCountingPersister()
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under, MaxPending: 5})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	for i := uint64(1); i <= 4; i++ {
+		_ = bp.Set("list", i)
+	}
+	if under.Writes() != 0 {
+		t.Errorf("expected no writes, got %d", under.Writes())
+	}
+
+	_ = bp.Set("list", 5)
+	if got, _ := under.Get("list"); got != 5 {
+		t.Errorf("expected %d, got %d", 5, got)
+	}
+}
+
+func TestBatchingPersister_Interval(t *testing.T) {
+	under := newCountingPersister()
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	_ = bp.Set("list", 3)
+
+	deadline := time.Now().Add(time.Second)
+	for under.Writes() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, _ := under.Get("list"); got != 3 {
+		t.Errorf("expected %d, got %d", 3, got)
+	}
+}
+
+func TestBatchingPersister_Close(t *testing.T) {
+	under := newCountingPersister()
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under, Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	_ = bp.Set("list", 7)
+	if err := bp.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if got, _ := under.Get("list"); got != 7 {
+		t.Errorf("expected %d, got %d", 7, got)
+	}
+}
+
+func TestBatchingPersister_FlushError(t *testing.T) {
+	under := newCountingPersister()
+	under.fail = true
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	_ = bp.Set("list", 1)
+	_ = bp.Set("other", 1)
+	if err := bp.Flush(); !errors.Is(err, errWrite) {
+		t.Errorf("wanted errWrite, got error = %v", err)
+	}
+	if bp.Pending() != 2 {
+		t.Errorf("expected failed values to stay pending, got %d", bp.Pending())
+	}
+
+	under.mu.Lock()
+	under.fail = false
+	under.mu.Unlock()
+
+	if err := bp.Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if got, _ := under.Get("other"); got != 1 {
+		t.Errorf("expected %d, got %d", 1, got)
+	}
+}
+
+func TestBatchingPersister_FlushesFileInOneSave(t *testing.T) {
+	for _, name := range []string{"ini", "json", "yaml"} {
+		t.Run(name, func(t *testing.T) {
+			under := conformingPersisters[name].open(t, filepath.Join(t.TempDir(), "pointers"))
+			defer closePersister(under)
+			bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+			if err != nil {
+				t.Fatalf("NewBatchingPersister() error = %v", err)
+			}
+			defer bp.Close()
+
+			saves := 0
+			writeFileAtomic = func(path string, data []byte) error {
+				saves++
+				return lizt.WriteFileAtomic(path, data)
+			}
+			defer func() { writeFileAtomic = lizt.WriteFileAtomic }()
+
+			for _, key := range []string{"a", "b", "c"} {
+				_ = bp.Set(key, 1)
+			}
+			if err := bp.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if saves != 1 {
+				t.Errorf("expected %d save, got %d", 1, saves)
+			}
+			for _, key := range []string{"a", "b", "c"} {
+				if got, err := under.Get(key); err != nil || got != 1 {
+					t.Errorf("Get(%s) = %d, %v, want %d", key, got, err, 1)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchingPersister_FailedTransactionKeepsBatchPending(t *testing.T) {
+	under, err := NewJSONPersister(filepath.Join(t.TempDir(), "pointers.json"))
+	if err != nil {
+		t.Fatalf("NewJSONPersister() error = %v", err)
+	}
+	defer under.Close()
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	_ = bp.Set("list", 1)
+	_ = bp.Set("other", 2)
+	writeFileAtomic = func(string, []byte) error { return errWrite }
+	err = bp.Flush()
+	writeFileAtomic = lizt.WriteFileAtomic
+
+	if !errors.Is(err, errWrite) {
+		t.Errorf("wanted errWrite, got error = %v", err)
+	}
+	if bp.Pending() != 2 {
+		t.Errorf("expected the whole batch to stay pending, got %d", bp.Pending())
+	}
+	if _, err = under.Get("list"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted ErrNotFound, got error = %v", err)
+	}
+
+	if err = bp.Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if got, _ := under.Get("other"); got != 2 {
+		t.Errorf("expected %d, got %d", 2, got)
+	}
+}
+
+func TestBatchingPersister_Delete(t *testing.T) {
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: newCountingPersister()})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+	if err := bp.Delete("list"); !errors.Is(err, ErrNoDeleter) {
+		t.Errorf("wanted ErrNoDeleter, got error = %v", err)
+	}
+}
+
+func TestBatchingPersister_NoPersister(t *testing.T) {
+	if _, err := NewBatchingPersister(BatchingPersisterConfig{}); !errors.Is(err, ErrNoPersister) {
+		t.Errorf("wanted ErrNoPersister, got error = %v", err)
+	}
+}
+
+func TestBatchingPersister_CrashReplaysAtMostMaxPending(t *testing.T) {
+	under := newCountingPersister()
+	lines := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under, MaxPending: 3})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+	first := lizt.B().SliceNamed("letters", lines, false).PersistTo(bp).MustBuild()
+	for i := 0; i < 7; i++ {
+		first.MustNextOne()
+	}
+	// crash: the pending pointer is never flushed
+
+	second := lizt.B().SliceNamed("letters", lines, false).PersistTo(under).MustBuild()
+	if line := second.MustNextOne(); line != "g" {
+		t.Errorf("expected to replay from %s, got %s", "g", line)
+	}
+}
+
+func TestBatchingPersister_IncrementFlushesFirst(t *testing.T) {
+	under := newCountingPersister()
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	_ = bp.Set("claims", 10)
+	if val, err := bp.Increment("claims", 5); err != nil || val != 15 {
+		t.Errorf("Increment() = %d, %v, want %d", val, err, 15)
+	}
+	if ok, err := bp.CompareAndSwap("claims", 15, 20); err != nil || !ok {
+		t.Errorf("CompareAndSwap() = %v, %v, want true", ok, err)
+	}
+	if got, _ := bp.Get("claims"); got != 20 {
+		t.Errorf("expected %d, got %d", 20, got)
+	}
+}
+
+// gatedPersister is a countingPersister whose Set waits for release, after signalling entered if anyone's waiting.
+type gatedPersister struct {
+	*countingPersister
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedPersister) Set(key string, value uint64) error {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.release
+	return g.countingPersister.Set(key, value)
+}
+
+func TestBatchingPersister_GetDuringFlush(t *testing.T) {
+	under := &gatedPersister{countingPersister: newCountingPersister(), entered: make(chan struct{}, 1), release: make(chan struct{})}
+	under.values["list"] = 1
+	bp, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+	if err != nil {
+		t.Fatalf("NewBatchingPersister() error = %v", err)
+	}
+	defer bp.Close()
+
+	_ = bp.Set("list", 5)
+	flushed := make(chan error, 1)
+	go func() { flushed <- bp.Flush() }()
+
+	<-under.entered
+	if got, err := bp.Get("list"); err != nil || got != 5 {
+		t.Errorf("Get() during a flush = %d, %v, want %d", got, err, 5)
+	}
+
+	under.mu.Lock()
+	under.fail = true
+	under.mu.Unlock()
+	close(under.release)
+	if err := <-flushed; !errors.Is(err, errWrite) {
+		t.Errorf("wanted errWrite, got error = %v", err)
+	}
+
+	if got, err := bp.Get("list"); err != nil || got != 5 {
+		t.Errorf("Get() after a failed flush = %d, %v, want %d", got, err, 5)
+	}
+	if bp.Pending() != 1 {
+		t.Errorf("expected the failed value to stay pending, got %d", bp.Pending())
+	}
+}
@@ -0,0 +1,207 @@
+package persist
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"git.faze.center/netr/lizt"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBoltTimeout is how long NewBoltPersister waits for another process to close the database.
+var DefaultBoltTimeout = time.Second
+
+var (
+	boltRoot       = []byte("lizt")
+	boltPointers   = []byte("pointers")
+	boltNamespaces = []byte("namespaces")
+)
+
+// BoltPersister is a persister that uses a bbolt database, which scales to many thousands of keys. Every Set is its
+// own transaction; Update writes several keys in one. Namespaces are laid out like the namespaces of JSONPersister:
+// every namespace is a bucket holding a "pointers" bucket and a "namespaces" bucket of its children.
+//
+// bbolt locks the database while it's open, so only one process can use it at a time.
+type BoltPersister struct {
+	db   *bolt.DB
+	path []string
+}
+
+// NewBoltPersister opens or creates the database at path. It waits DefaultBoltTimeout for another process to close
+// the database before returning an error.
+func NewBoltPersister(path string) (*BoltPersister, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: DefaultBoltTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt file: %s -> %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltRoot)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to init bolt file: %s -> %w", path, err)
+	}
+	return &BoltPersister{db: db}, nil
+}
+
+// Namespace returns the child namespace with the given name. It shares the database with its parent.
+func (b *BoltPersister) Namespace(name string) *BoltPersister {
+	path := make([]string, len(b.path), len(b.path)+1)
+	copy(path, b.path)
+	return &BoltPersister{db: b.db, path: append(path, name)}
+}
+
+// Set sets the value of a key
+func (b *BoltPersister) Set(key string, value uint64) error {
+	return b.Update(func(tx lizt.Persister) error {
+		return tx.Set(key, value)
+	})
+}
+
+// Get gets the value of a key
+func (b *BoltPersister) Get(key string) (uint64, error) {
+	var val uint64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		val, err = (&boltTx{tx: tx, path: b.path}).Get(key)
+		return err
+	})
+	return val, err
+}
+
+// Increment adds delta to the value of a key, a missing key being 0, and returns the new value.
+func (b *BoltPersister) Increment(key string, delta uint64) (uint64, error) {
+	var val uint64
+	err := b.Update(func(tx lizt.Persister) error {
+		var err error
+		val, err = tx.Increment(key, delta)
+		return err
+	})
+	return val, err
+}
+
+// CompareAndSwap sets the value of a key to new if it's old, a missing key being 0. It reports whether it did.
+func (b *BoltPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	var swapped bool
+	err := b.Update(func(tx lizt.Persister) error {
+		var err error
+		swapped, err = tx.CompareAndSwap(key, old, new)
+		return err
+	})
+	return swapped, err
+}
+
+// Delete deletes a key
+func (b *BoltPersister) Delete(key string) error {
+	return b.Update(func(tx lizt.Persister) error {
+		return tx.(lizt.Deleter).Delete(key)
+	})
+}
+
+// Update runs fn in a read-write transaction. Everything fn writes is committed together if it returns nil, and
+// discarded otherwise. The persister passed to fn is only valid until fn returns.
+func (b *BoltPersister) Update(fn func(tx lizt.Persister) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx, path: b.path, writable: true})
+	})
+}
+
+// Close closes the database, which is shared by every namespace.
+func (b *BoltPersister) Close() error {
+	return b.db.Close()
+}
+
+// boltTx is the persister of one transaction.
+type boltTx struct {
+	tx       *bolt.Tx
+	path     []string
+	writable bool
+}
+
+// pointers returns the pointers bucket of the namespace, creating it in a writable transaction. It returns nil if
+// it doesn't exist.
+func (t *boltTx) pointers() (*bolt.Bucket, error) {
+	bucket := t.tx.Bucket(boltRoot)
+	for _, name := range t.path {
+		var err error
+		if bucket, err = t.child(bucket, boltNamespaces); bucket == nil || err != nil {
+			return nil, err
+		}
+		if bucket, err = t.child(bucket, []byte(name)); bucket == nil || err != nil {
+			return nil, err
+		}
+	}
+	return t.child(bucket, boltPointers)
+}
+
+func (t *boltTx) child(bucket *bolt.Bucket, name []byte) (*bolt.Bucket, error) {
+	if !t.writable {
+		return bucket.Bucket(name), nil
+	}
+	return bucket.CreateBucketIfNotExists(name)
+}
+
+func (t *boltTx) Set(key string, value uint64) error {
+	bucket, err := t.pointers()
+	if err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], value)
+	return bucket.Put([]byte(key), buf[:])
+}
+
+func (t *boltTx) Get(key string) (uint64, error) {
+	bucket, err := t.pointers()
+	if err != nil {
+		return 0, err
+	}
+	if bucket == nil {
+		return 0, ErrNotFound
+	}
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return 0, ErrNotFound
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("bolt: key %s -> %w", key, lizt.ErrCorruptState)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (t *boltTx) Delete(key string) error {
+	bucket, err := t.pointers()
+	if err != nil {
+		return err
+	}
+	return bucket.Delete([]byte(key))
+}
+
+// value returns the value of a key, a missing key being 0.
+func (t *boltTx) value(key string) (uint64, error) {
+	val, err := t.Get(key)
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	return val, err
+}
+
+func (t *boltTx) Increment(key string, delta uint64) (uint64, error) {
+	val, err := t.value(key)
+	if err != nil {
+		return 0, err
+	}
+	val += delta
+	return val, t.Set(key, val)
+}
+
+func (t *boltTx) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	val, err := t.value(key)
+	if err != nil || val != old {
+		return false, err
+	}
+	return true, t.Set(key, new)
+}
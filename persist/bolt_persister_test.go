@@ -0,0 +1,109 @@
+package persist
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestBoltPersister_Update(t *testing.T) {
+	p, err := NewBoltPersister(filepath.Join(t.TempDir(), "pointers.db"))
+	if err != nil {
+		t.Fatalf("NewBoltPersister() error = %v", err)
+	}
+	defer p.Close()
+
+	err = p.Update(func(tx lizt.Persister) error {
+		if err := tx.Set("list", 5); err != nil {
+			return err
+		}
+		return tx.Set("list.seed.planted", 2)
+	})
+	if err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	for key, want := range map[string]uint64{"list": 5, "list.seed.planted": 2} {
+		if got, err := p.Get(key); err != nil || got != want {
+			t.Errorf("Get(%s) = %d, %v, want %d", key, got, err, want)
+		}
+	}
+}
+
+func TestBoltPersister_UpdateRollback(t *testing.T) {
+	p, err := NewBoltPersister(filepath.Join(t.TempDir(), "pointers.db"))
+	if err != nil {
+		t.Fatalf("NewBoltPersister() error = %v", err)
+	}
+	defer p.Close()
+	errAbort := errors.New("abort")
+
+	err = p.Update(func(tx lizt.Persister) error {
+		_ = tx.Set("list", 5)
+		_ = tx.Set("list.seed.planted", 2)
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("wanted errAbort, got error = %v", err)
+	}
+	if _, err = p.Get("list"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted ErrNotFound after a rollback, got error = %v", err)
+	}
+}
+
+func TestBoltPersister_Namespace_Update(t *testing.T) {
+	p, err := NewBoltPersister(filepath.Join(t.TempDir(), "pointers.db"))
+	if err != nil {
+		t.Fatalf("NewBoltPersister() error = %v", err)
+	}
+	defer p.Close()
+	ns := p.Namespace("manager")
+
+	_ = ns.Update(func(tx lizt.Persister) error {
+		return tx.Set("list", 3)
+	})
+	if got, err := ns.Get("list"); err != nil || got != 3 {
+		t.Errorf("Get() = %d, %v, want %d", got, err, 3)
+	}
+	if _, err := p.Get("list"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted ErrNotFound outside the namespace, got error = %v", err)
+	}
+}
+
+func TestBoltPersister_Exclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.db")
+	p, err := NewBoltPersister(path)
+	if err != nil {
+		t.Fatalf("NewBoltPersister() error = %v", err)
+	}
+	defer p.Close()
+
+	timeout := DefaultBoltTimeout
+	DefaultBoltTimeout = 50 * time.Millisecond
+	defer func() { DefaultBoltTimeout = timeout }()
+
+	if _, err := NewBoltPersister(path); err == nil {
+		t.Errorf("Expected an error while the database is open")
+	}
+}
+
+func TestBoltPersister_Builder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.db")
+	p, err := NewBoltPersister(path)
+	if err != nil {
+		t.Fatalf("NewBoltPersister() error = %v", err)
+	}
+	defer p.Close()
+	lines := []string{"a", "b", "c", "d", "e"}
+
+	iter := lizt.B().SliceNamed("letters", lines, false).PersistTo(p).MustBuildWithSeeds(2, []string{"s1", "s2", "s3"})
+	iter.MustNext(4)
+
+	for key, want := range map[string]uint64{"letters": 2, "letters.seed.planted": 2, "letters.seed.pointer": 2} {
+		if got, err := p.Get(key); err != nil || got != want {
+			t.Errorf("Get(%s) = %d, %v, want %d", key, got, err, want)
+		}
+	}
+}
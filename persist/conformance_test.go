@@ -0,0 +1,387 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+// persisterFactory opens a persister on path. Opening the same path again, after the first persister is closed, must
+// see the values saved before. Exclusive persisters lock the file while they're open, so it can't be opened twice at
+// the same time. Write-behind persisters only write their values on a flush, so other persisters on the same path
+// don't see them before.
+type persisterFactory struct {
+	open        func(t *testing.T, path string) lizt.Persister
+	exclusive   bool
+	writeBehind bool
+}
+
+// conformingPersisters are the persisters that must pass testPersisterConformance.
+var conformingPersisters = map[string]persisterFactory{
+	"ini": {open: func(t *testing.T, path string) lizt.Persister {
+		p, err := NewIniPersister(path)
+		if err != nil {
+			t.Fatalf("NewIniPersister() error = %v", err)
+		}
+		return p
+	}},
+	"json": {open: func(t *testing.T, path string) lizt.Persister {
+		p, err := NewJSONPersister(path)
+		if err != nil {
+			t.Fatalf("NewJSONPersister() error = %v", err)
+		}
+		return p
+	}},
+	"yaml": {open: func(t *testing.T, path string) lizt.Persister {
+		p, err := NewYAMLPersister(path)
+		if err != nil {
+			t.Fatalf("NewYAMLPersister() error = %v", err)
+		}
+		return p
+	}},
+	"bolt": {open: func(t *testing.T, path string) lizt.Persister {
+		p, err := NewBoltPersister(path)
+		if err != nil {
+			t.Fatalf("NewBoltPersister() error = %v", err)
+		}
+		return p
+	}, exclusive: true},
+	"redis": {open: func(t *testing.T, path string) lizt.Persister {
+		// the path only isolates the keys: every test shares one in-process server
+		p, err := NewRedisPersister(RedisPersisterConfig{Client: testRedisClient(t), Prefix: path + ":"})
+		if err != nil {
+			t.Fatalf("NewRedisPersister() error = %v", err)
+		}
+		return p
+	}},
+	"batching": {open: func(t *testing.T, path string) lizt.Persister {
+		under, err := NewJSONPersister(path)
+		if err != nil {
+			t.Fatalf("NewJSONPersister() error = %v", err)
+		}
+		t.Cleanup(func() { _ = under.Close() })
+
+		p, err := NewBatchingPersister(BatchingPersisterConfig{Persister: under})
+		if err != nil {
+			t.Fatalf("NewBatchingPersister() error = %v", err)
+		}
+		return p
+	}, writeBehind: true},
+}
+
+func TestPersisterConformance(t *testing.T) {
+	for name, factory := range conformingPersisters {
+		t.Run(name, func(t *testing.T) {
+			testPersisterConformance(t, factory)
+		})
+	}
+}
+
+// closePersister closes p if it can be closed.
+func closePersister(p lizt.Persister) {
+	if c, ok := p.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// namespace returns the child namespace of p with the given name, if p has namespaces.
+func namespace(p lizt.Persister, name string) (lizt.Persister, bool) {
+	method := reflect.ValueOf(p).MethodByName("Namespace")
+	if !method.IsValid() {
+		return nil, false
+	}
+	ns, ok := method.Call([]reflect.Value{reflect.ValueOf(name)})[0].Interface().(lizt.Persister)
+	return ns, ok
+}
+
+// testPersisterConformance checks the behaviour every persister must have.
+func testPersisterConformance(t *testing.T, factory persisterFactory) {
+	newPath := func(t *testing.T) string {
+		return filepath.Join(t.TempDir(), "pointers")
+	}
+	open := func(t *testing.T, path string) lizt.Persister {
+		p := factory.open(t, path)
+		t.Cleanup(func() { closePersister(p) })
+		return p
+	}
+
+	t.Run("GetMissing", func(t *testing.T) {
+		p := open(t, newPath(t))
+		if _, err := p.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("wanted ErrNotFound, got error = %v", err)
+		}
+	})
+
+	t.Run("SetGet", func(t *testing.T) {
+		p := open(t, newPath(t))
+		values := map[string]uint64{"zero": 0, "one": 1, "list.shuffle.seed": 42, "max": math.MaxUint64}
+		for key, value := range values {
+			if err := p.Set(key, value); err != nil {
+				t.Errorf("Set(%s) error = %v", key, err)
+			}
+		}
+		for key, value := range values {
+			got, err := p.Get(key)
+			if err != nil || got != value {
+				t.Errorf("Get(%s) = %d, %v, want %d", key, got, err, value)
+			}
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		p := open(t, newPath(t))
+		for _, value := range []uint64{5, 3, 9} {
+			if err := p.Set("list", value); err != nil {
+				t.Errorf("Set() error = %v", err)
+			}
+		}
+		if got, _ := p.Get("list"); got != 9 {
+			t.Errorf("expected %d, got %d", 9, got)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		path := newPath(t)
+		p := open(t, path)
+		if err := p.Set("list", 7); err != nil {
+			t.Errorf("Set() error = %v", err)
+		}
+		closePersister(p)
+
+		if got, err := open(t, path).Get("list"); err != nil || got != 7 {
+			t.Errorf("Get() = %d, %v, want %d", got, err, 7)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		path := newPath(t)
+		p := open(t, path)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := p.Set(fmt.Sprintf("list%d", i), uint64(i)); err != nil {
+					t.Errorf("Set() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		closePersister(p)
+
+		reopened := open(t, path)
+		for i := 0; i < 10; i++ {
+			if got, err := reopened.Get(fmt.Sprintf("list%d", i)); err != nil || got != uint64(i) {
+				t.Errorf("Get(list%d) = %d, %v, want %d", i, got, err, i)
+			}
+		}
+	})
+
+	t.Run("NoTempFiles", func(t *testing.T) {
+		path := newPath(t)
+		p := open(t, path)
+		for i := 0; i < 10; i++ {
+			_ = p.Set("list", uint64(i))
+		}
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			t.Fatalf("ReadDir() error = %v", err)
+		}
+		for _, entry := range entries {
+			switch entry.Name() {
+			case filepath.Base(path), filepath.Base(path) + ".bak", filepath.Base(path) + ".lock":
+			default:
+				t.Errorf("expected no temp files, found %s", entry.Name())
+			}
+		}
+	})
+
+	t.Run("Increment", func(t *testing.T) {
+		p := open(t, newPath(t))
+		for _, want := range []uint64{5, 10} {
+			if got, err := p.Increment("claims", 5); err != nil || got != want {
+				t.Errorf("Increment() = %d, %v, want %d", got, err, want)
+			}
+		}
+		if got, err := p.Get("claims"); err != nil || got != 10 {
+			t.Errorf("Get() = %d, %v, want %d", got, err, 10)
+		}
+	})
+
+	t.Run("CompareAndSwap", func(t *testing.T) {
+		p := open(t, newPath(t))
+		if ok, err := p.CompareAndSwap("list", 0, 7); err != nil || !ok {
+			t.Errorf("CompareAndSwap() on a missing key = %v, %v, want true", ok, err)
+		}
+		if ok, err := p.CompareAndSwap("list", 1, 9); err != nil || ok {
+			t.Errorf("CompareAndSwap() with a stale value = %v, %v, want false", ok, err)
+		}
+		if ok, err := p.CompareAndSwap("list", 7, 9); err != nil || !ok {
+			t.Errorf("CompareAndSwap() = %v, %v, want true", ok, err)
+		}
+		if got, _ := p.Get("list"); got != 9 {
+			t.Errorf("expected %d, got %d", 9, got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		p := factory.open(t, filepath.Join(t.TempDir(), "pointers"))
+		defer closePersister(p)
+
+		d, ok := p.(lizt.Deleter)
+		if !ok {
+			t.Fatalf("expected %T to be a lizt.Deleter", p)
+		}
+		if err := d.Delete("missing"); err != nil {
+			t.Errorf("Delete() of a missing key error = %v", err)
+		}
+		_ = p.Set("a", 1)
+		_ = p.Set("b", 2)
+		if err := d.Delete("a"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := p.Get("a"); err == nil {
+			t.Errorf("expected a to be deleted")
+		}
+		if val, err := p.Get("b"); err != nil || val != 2 {
+			t.Errorf("expected b to be 2, got %d, error = %v", val, err)
+		}
+	})
+
+	t.Run("SharedFile", func(t *testing.T) {
+		if factory.exclusive {
+			t.Skip("exclusive")
+		}
+		if factory.writeBehind {
+			t.Skip("write-behind")
+		}
+		path := newPath(t)
+		first, second := open(t, path), open(t, path)
+
+		_ = first.Set("first", 1)
+		_ = second.Set("second", 2)
+		if got, err := first.Get("second"); err != nil || got != 2 {
+			t.Errorf("Get() = %d, %v, want %d", got, err, 2)
+		}
+		if got, err := open(t, path).Get("first"); err != nil || got != 1 {
+			t.Errorf("Get() = %d, %v, want %d", got, err, 1)
+		}
+	})
+
+	t.Run("SharedFileIncrement", func(t *testing.T) {
+		if factory.exclusive {
+			t.Skip("exclusive")
+		}
+		path := newPath(t)
+		persisters := []lizt.Persister{open(t, path), open(t, path)}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(p lizt.Persister) {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					if _, err := p.Increment("claims", 1); err != nil {
+						t.Errorf("Increment() error = %v", err)
+					}
+				}
+			}(persisters[i%2])
+		}
+		wg.Wait()
+
+		if got, _ := open(t, path).Get("claims"); got != 100 {
+			t.Errorf("expected %d, got %d", 100, got)
+		}
+	})
+
+	t.Run("PersistentIterator", func(t *testing.T) {
+		path := newPath(t)
+		lines := []string{"a", "b", "c", "d"}
+
+		p := open(t, path)
+		first := lizt.B().SliceNamed("letters", lines, false).PersistTo(p).MustBuild()
+		first.MustNext(2)
+		closePersister(p)
+
+		second := lizt.B().SliceNamed("letters", lines, false).PersistTo(open(t, path)).MustBuild()
+		if line := second.MustNextOne(); line != "c" {
+			t.Errorf("expected %s, got %s", "c", line)
+		}
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		path := newPath(t)
+		root := open(t, path)
+		if _, ok := namespace(root, "first"); !ok {
+			t.Skip("no namespaces")
+		}
+
+		namespaces := func(root lizt.Persister) []lizt.Persister {
+			first, _ := namespace(root, "first")
+			second, _ := namespace(root, "second")
+			nested, _ := namespace(first, "nested")
+			return []lizt.Persister{root, first, second, nested}
+		}
+
+		for i, p := range namespaces(root) {
+			if err := p.Set("list", uint64(i)); err != nil {
+				t.Errorf("Set() error = %v", err)
+			}
+		}
+		closePersister(root)
+
+		reopened := open(t, path)
+		for i, p := range namespaces(reopened) {
+			if got, err := p.Get("list"); err != nil || got != uint64(i) {
+				t.Errorf("namespace %d: Get() = %d, %v, want %d", i, got, err, i)
+			}
+		}
+		third, _ := namespace(reopened, "third")
+		if _, err := third.Get("list"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("wanted ErrNotFound, got error = %v", err)
+		}
+	})
+}
+
+func TestFilePersisters_FailedSave(t *testing.T) {
+	for _, name := range []string{"ini", "json", "yaml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pointers")
+			p := conformingPersisters[name].open(t, path)
+			defer closePersister(p)
+
+			if err := p.Set("list", 1); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			errDisk := errors.New("disk full")
+			writeFileAtomic = func(string, []byte) error { return errDisk }
+			err := p.Set("list", 2)
+			_, incErr := p.Increment("claims", 5)
+			writeFileAtomic = lizt.WriteFileAtomic
+
+			if !errors.Is(err, errDisk) || !errors.Is(incErr, errDisk) {
+				t.Errorf("wanted the write error, got %v and %v", err, incErr)
+			}
+			if got, err := p.Get("list"); err != nil || got != 1 {
+				t.Errorf("Get() = %d, %v, want %d", got, err, 1)
+			}
+			if _, err = p.Get("claims"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("wanted ErrNotFound, got error = %v", err)
+			}
+			if got, err := p.Increment("claims", 5); err != nil || got != 5 {
+				t.Errorf("Increment() = %d, %v, want %d", got, err, 5)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package persist
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"git.faze.center/netr/lizt"
+)
+
+// writeFileAtomic saves the files of the ini and document persisters. Tests replace it to make a save fail.
+var writeFileAtomic = lizt.WriteFileAtomic
+
+// docNode is a namespace in a document persister file. Pointers holds its keys and Namespaces its children.
+type docNode struct {
+	Pointers   map[string]uint64   `json:"pointers,omitempty" yaml:"pointers,omitempty"`
+	Namespaces map[string]*docNode `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+// docPersister is the shared core of the persisters that keep the whole document in memory and rewrite the file
+// atomically on every Set. Like IniPersister, it locks "<path>.lock" and rereads the file when another process
+// saved it, so several processes can share the file.
+type docPersister struct {
+	path      string
+	root      *docNode
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+	lock      *fileLock
+	stamp     fileStamp
+	mu        sync.Mutex
+}
+
+// newDocPersister loads the document at path, or starts an empty one if the file doesn't exist or is empty. A file
+// that can't be parsed returns an error wrapping lizt.ErrCorruptState.
+func newDocPersister(path string, marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) (*docPersister, error) {
+	lock, err := openFileLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = lock.Lock(); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	d := &docPersister{
+		path:      path,
+		root:      &docNode{},
+		marshal:   marshal,
+		unmarshal: unmarshal,
+		lock:      lock,
+	}
+	if err = d.load(); err != nil && !os.IsNotExist(err) {
+		_ = lock.Close()
+		return nil, err
+	}
+	if err = d.save(d.root); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// load reads the document from the file.
+func (d *docPersister) load() error {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("failed to read file: %s -> %w", d.path, err)
+	}
+
+	root := &docNode{}
+	if len(data) > 0 {
+		if err = d.unmarshal(data, root); err != nil {
+			return fmt.Errorf("failed to parse file: %s: %v -> %w", d.path, err, lizt.ErrCorruptState)
+		}
+	}
+	d.root = root
+	d.stamp.stamp(d.path)
+	return nil
+}
+
+// refresh rereads the file if another process saved it since it was last read or written. It must be called with
+// the file lock held.
+func (d *docPersister) refresh() error {
+	if !d.stamp.changed(d.path) {
+		return nil
+	}
+	if err := d.load(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// clone returns a deep copy of the namespace and its children.
+func (n *docNode) clone() *docNode {
+	c := &docNode{}
+	if n.Pointers != nil {
+		c.Pointers = make(map[string]uint64, len(n.Pointers))
+		for k, v := range n.Pointers {
+			c.Pointers[k] = v
+		}
+	}
+	if n.Namespaces != nil {
+		c.Namespaces = make(map[string]*docNode, len(n.Namespaces))
+		for name, child := range n.Namespaces {
+			c.Namespaces[name] = child.clone()
+		}
+	}
+	return c
+}
+
+// node returns the namespace at path below root, creating it if create is true. It returns nil if it doesn't exist.
+func node(root *docNode, path []string, create bool) *docNode {
+	n := root
+	for _, name := range path {
+		child, ok := n.Namespaces[name]
+		if !ok {
+			if !create {
+				return nil
+			}
+			if n.Namespaces == nil {
+				n.Namespaces = make(map[string]*docNode)
+			}
+			child = &docNode{}
+			n.Namespaces[name] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// update runs fn on the pointers of the namespace at path under the exclusive file lock, and saves the file if fn
+// reports a change. fn works on a copy of the document, which only replaces the one in memory once the file is
+// written, so a failed save leaves both as they were.
+func (d *docPersister) update(path []string, fn func(pointers map[string]uint64) bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.lock.Lock(); err != nil {
+		return err
+	}
+	defer d.lock.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return err
+	}
+	root := d.root.clone()
+	n := node(root, path, true)
+	if n.Pointers == nil {
+		n.Pointers = make(map[string]uint64)
+	}
+	if !fn(n.Pointers) {
+		return nil
+	}
+	if err := d.save(root); err != nil {
+		return err
+	}
+	d.root = root
+	return nil
+}
+
+func (d *docPersister) get(path []string, key string) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.lock.RLock(); err != nil {
+		return 0, err
+	}
+	defer d.lock.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return 0, err
+	}
+	n := node(d.root, path, false)
+	if n == nil {
+		return 0, ErrNotFound
+	}
+	val, ok := n.Pointers[key]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return val, nil
+}
+
+// save writes root atomically, so a crash leaves either the old or the new file.
+func (d *docPersister) save(root *docNode) error {
+	data, err := d.marshal(root)
+	if err != nil {
+		return fmt.Errorf("failed to encode file: %s -> %w", d.path, err)
+	}
+	if err = writeFileAtomic(d.path, data); err != nil {
+		return fmt.Errorf("failed to save file: %s -> %w", d.path, err)
+	}
+	d.stamp.stamp(d.path)
+	return nil
+}
+
+// Namespace is a persister that keeps its keys apart from the keys of other namespaces in the same file, e.g. one
+// namespace per Manager. Namespaces can be nested.
+type Namespace struct {
+	doc  *docPersister
+	path []string
+}
+
+// Namespace returns the child namespace with the given name.
+func (n *Namespace) Namespace(name string) *Namespace {
+	path := make([]string, len(n.path), len(n.path)+1)
+	copy(path, n.path)
+	return &Namespace{doc: n.doc, path: append(path, name)}
+}
+
+// Set sets the value of a key
+func (n *Namespace) Set(key string, value uint64) error {
+	return n.Update(func(tx lizt.Persister) error {
+		return tx.Set(key, value)
+	})
+}
+
+// Get gets the value of a key
+func (n *Namespace) Get(key string) (uint64, error) {
+	return n.doc.get(n.path, key)
+}
+
+// Increment adds delta to the value of a key, a missing key being 0, and returns the new value.
+func (n *Namespace) Increment(key string, delta uint64) (uint64, error) {
+	var val uint64
+	err := n.Update(func(tx lizt.Persister) error {
+		var err error
+		val, err = tx.Increment(key, delta)
+		return err
+	})
+	return val, err
+}
+
+// CompareAndSwap sets the value of a key to new if it's old, a missing key being 0. It reports whether it did.
+func (n *Namespace) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	var swapped bool
+	err := n.Update(func(tx lizt.Persister) error {
+		var err error
+		swapped, err = tx.CompareAndSwap(key, old, new)
+		return err
+	})
+	return swapped, err
+}
+
+// Delete deletes a key
+func (n *Namespace) Delete(key string) error {
+	return n.Update(func(tx lizt.Persister) error {
+		return tx.(lizt.Deleter).Delete(key)
+	})
+}
+
+// Update runs fn on the namespace under the exclusive file lock and writes everything it changed with a single save
+// if it returns nil. If fn returns an error nothing is written. The persister passed to fn is only valid until fn
+// returns, and fn must not use the persister itself.
+func (n *Namespace) Update(fn func(tx lizt.Persister) error) error {
+	var err error
+	saveErr := n.doc.update(n.path, func(pointers map[string]uint64) bool {
+		tx := &docTx{pointers: pointers}
+		err = fn(tx)
+		return err == nil && tx.changed
+	})
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// docTx is the persister of one Update. It works on the pointers of the staged copy of the document.
+type docTx struct {
+	pointers map[string]uint64
+	changed  bool
+}
+
+func (t *docTx) Set(key string, value uint64) error {
+	t.pointers[key] = value
+	t.changed = true
+	return nil
+}
+
+func (t *docTx) Get(key string) (uint64, error) {
+	val, ok := t.pointers[key]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return val, nil
+}
+
+func (t *docTx) Increment(key string, delta uint64) (uint64, error) {
+	val := t.pointers[key] + delta
+	return val, t.Set(key, val)
+}
+
+func (t *docTx) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	if t.pointers[key] != old {
+		return false, nil
+	}
+	return true, t.Set(key, new)
+}
+
+func (t *docTx) Delete(key string) error {
+	if _, ok := t.pointers[key]; ok {
+		delete(t.pointers, key)
+		t.changed = true
+	}
+	return nil
+}
@@ -1,66 +1,341 @@
 package persist
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"os"
+	"strconv"
 	"sync"
 
 	"git.faze.center/netr/lizt"
 	"gopkg.in/ini.v1"
 )
 
-// IniPersister is a persister that uses an ini file
+// iniChecksumPrefix starts the first line of an ini file, followed by the CRC-32 of the rest of the file. Files
+// without it, e.g. written by hand or by older versions, aren't checked.
+const iniChecksumPrefix = "; lizt crc32="
+
+// IniPersister is a persister that uses an ini file. Every Set rewrites the file atomically and keeps the previous
+// copy as "<path>.bak", which NewIniPersister falls back to if the file is corrupt. Update writes several keys with
+// one rewrite.
+//
+// Several processes can share the file: every operation takes an advisory lock on "<path>.lock" and rereads the
+// file if another process saved it since, so Set, Increment and CompareAndSwap are read-modify-writes.
 type IniPersister struct {
 	lizt.PersistentIterator
 	iniPath string
-	mu      sync.RWMutex
+	mu      sync.Mutex
 	iniFile *ini.File
+	valid   bool
+	lock    *fileLock
+	stamp   fileStamp
 }
 
-// NewIniPersister creates a new thread-safe IniPersister instance using the given path
+// NewIniPersister creates a new thread-safe IniPersister instance using the given path. The file is created if it
+// doesn't exist. If it's corrupt, the backup copy is used instead, and if that's missing or corrupt too, an error
+// wrapping lizt.ErrCorruptState is returned rather than starting over from empty pointers.
 func NewIniPersister(iniPath string) (*IniPersister, error) {
-	if !lizt.DoesFileExist(iniPath) {
-		_, err := os.Create(iniPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create ini file: %s -> %w", iniPath, err)
+	lock, err := openFileLock(iniPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = lock.Lock(); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	i := &IniPersister{iniPath: iniPath, lock: lock}
+	if err = i.load(); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	if err = i.save(i.iniFile); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	return i, nil
+}
+
+// load reads the file, or the backup if the file is corrupt.
+func (i *IniPersister) load() error {
+	cfg, err := loadIni(i.iniPath)
+	switch {
+	case err == nil:
+		i.iniFile, i.valid = cfg, true
+	case os.IsNotExist(err):
+		i.iniFile = ini.Empty()
+	case errors.Is(err, lizt.ErrCorruptState):
+		backup, backupErr := loadIni(i.iniPath + ".bak")
+		if backupErr != nil {
+			return err
+		}
+		i.iniFile = backup
+	default:
+		return err
+	}
+	return nil
+}
+
+// refresh rereads the file if another process saved it since it was last read or written. It must be called with
+// the file lock held.
+func (i *IniPersister) refresh() error {
+	if !i.stamp.changed(i.iniPath) {
+		return nil
+	}
+
+	cfg, err := loadIni(i.iniPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// removed from under us: the next save writes it again
+			return nil
 		}
+		return err
 	}
-	cfg, err := ini.Load(iniPath)
+	i.iniFile, i.valid = cfg, true
+	i.stamp.stamp(i.iniPath)
+	return nil
+}
+
+// loadIni reads and verifies an ini file. The errors of a bad checksum, bad syntax or a bad value wrap
+// lizt.ErrCorruptState.
+func loadIni(path string) (*ini.File, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read ini file: %s -> %w", iniPath, err)
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read ini file: %s -> %w", path, err)
+	}
+
+	if bytes.HasPrefix(data, []byte(iniChecksumPrefix)) {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("ini file: %s: truncated -> %w", path, lizt.ErrCorruptState)
+		}
+		sum, err := strconv.ParseUint(string(data[len(iniChecksumPrefix):end]), 16, 32)
+		data = data[end+1:]
+		if err != nil || uint32(sum) != crc32.ChecksumIEEE(data) {
+			return nil, fmt.Errorf("ini file: %s: checksum mismatch -> %w", path, lizt.ErrCorruptState)
+		}
 	}
 
-	return &IniPersister{
-		iniFile: cfg,
-		iniPath: iniPath,
-	}, nil
+	cfg, err := ini.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("ini file: %s: %v -> %w", path, err, lizt.ErrCorruptState)
+	}
+	for _, key := range cfg.Section("pointers").Keys() {
+		if _, err = key.Uint64(); err != nil {
+			return nil, fmt.Errorf("ini file: %s: key %s -> %w", path, key.Name(), lizt.ErrCorruptState)
+		}
+	}
+	return cfg, nil
 }
 
-// Set sets the value of a key
-func (i *IniPersister) Set(key string, value uint64) error {
+// save writes cfg atomically with its checksum. The file it replaces becomes the backup, unless it was corrupt.
+func (i *IniPersister) save(cfg *ini.File) error {
+	var body bytes.Buffer
+	if _, err := cfg.WriteTo(&body); err != nil {
+		return fmt.Errorf("failed to encode ini file: %s -> %w", i.iniPath, err)
+	}
+
+	data := []byte(fmt.Sprintf("%s%08x\n", iniChecksumPrefix, crc32.ChecksumIEEE(body.Bytes())))
+	data = append(data, body.Bytes()...)
+
+	if i.valid {
+		i.backup()
+	}
+	if err := writeFileAtomic(i.iniPath, data); err != nil {
+		return fmt.Errorf("failed to save ini file: %s -> %w", i.iniPath, err)
+	}
+	i.valid = true
+	i.stamp.stamp(i.iniPath)
+	return nil
+}
+
+// backup hard links the current file as the backup. The atomic write that follows replaces the file, so the backup
+// keeps the previous version without copying it. It's best effort: file systems without hard links get no backup.
+func (i *IniPersister) backup() {
+	tmp := i.iniPath + ".bak.tmp"
+	_ = os.Remove(tmp)
+	if err := os.Link(i.iniPath, tmp); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, i.iniPath+".bak"); err != nil {
+		_ = os.Remove(tmp)
+	}
+}
+
+// update runs fn on the pointers section under the exclusive file lock and saves the file if fn reports a change.
+// fn works on a copy of the file, which only replaces the one in memory once it's written, so a failed save leaves
+// both as they were.
+func (i *IniPersister) update(fn func(section *ini.Section) (bool, error)) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	i.iniFile.Section("pointers").Key(key).SetValue(fmt.Sprintf("%d", value))
-	err := i.iniFile.SaveTo(i.iniPath)
+	if err := i.lock.Lock(); err != nil {
+		return err
+	}
+	defer i.lock.Unlock()
+
+	if err := i.refresh(); err != nil {
+		return err
+	}
+	staged, err := i.clone()
 	if err != nil {
-		return fmt.Errorf("failed to save ini file: %s -> %w", i.iniPath, err)
+		return err
 	}
+	changed, err := fn(staged.Section("pointers"))
+	if err != nil || !changed {
+		return err
+	}
+	if err = i.save(staged); err != nil {
+		return err
+	}
+	i.iniFile = staged
 	return nil
 }
 
+// clone returns a copy of the file in memory.
+func (i *IniPersister) clone() (*ini.File, error) {
+	var body bytes.Buffer
+	if _, err := i.iniFile.WriteTo(&body); err != nil {
+		return nil, fmt.Errorf("failed to encode ini file: %s -> %w", i.iniPath, err)
+	}
+	cfg, err := ini.Load(body.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy ini file: %s -> %w", i.iniPath, err)
+	}
+	return cfg, nil
+}
+
+// value returns the value of a key in section. A value that isn't a number returns an error wrapping
+// lizt.ErrCorruptState.
+func (i *IniPersister) value(section *ini.Section, key string) (uint64, error) {
+	if section == nil || !section.HasKey(key) {
+		return 0, ErrNotFound
+	}
+
+	val, err := section.Key(key).Uint64()
+	if err != nil {
+		return 0, fmt.Errorf("ini file: %s: key %s -> %w", i.iniPath, key, lizt.ErrCorruptState)
+	}
+	return val, nil
+}
+
+// Set sets the value of a key
+func (i *IniPersister) Set(key string, value uint64) error {
+	return i.Update(func(tx lizt.Persister) error {
+		return tx.Set(key, value)
+	})
+}
+
 var ErrNotFound = errors.New("not found")
 
-// Get gets the value of a key
+// Get gets the value of a key. A value that isn't a number returns an error wrapping lizt.ErrCorruptState.
 func (i *IniPersister) Get(key string) (uint64, error) {
-	i.mu.RLock()
-	defer i.mu.RUnlock()
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-	val, err := i.iniFile.Section("pointers").Key(key).Uint64()
-	if err != nil {
-		return 0, ErrNotFound
+	if err := i.lock.RLock(); err != nil {
+		return 0, err
 	}
+	defer i.lock.Unlock()
 
-	return val, nil
+	if err := i.refresh(); err != nil {
+		return 0, err
+	}
+	section, _ := i.iniFile.GetSection("pointers")
+	return i.value(section, key)
+}
+
+// Increment adds delta to the value of a key, a missing key being 0, and returns the new value.
+func (i *IniPersister) Increment(key string, delta uint64) (uint64, error) {
+	var val uint64
+	err := i.Update(func(tx lizt.Persister) error {
+		var err error
+		val, err = tx.Increment(key, delta)
+		return err
+	})
+	return val, err
+}
+
+// CompareAndSwap sets the value of a key to new if it's old, a missing key being 0. It reports whether it did.
+func (i *IniPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	var swapped bool
+	err := i.Update(func(tx lizt.Persister) error {
+		var err error
+		swapped, err = tx.CompareAndSwap(key, old, new)
+		return err
+	})
+	return swapped, err
+}
+
+// Delete deletes a key
+func (i *IniPersister) Delete(key string) error {
+	return i.Update(func(tx lizt.Persister) error {
+		return tx.(lizt.Deleter).Delete(key)
+	})
+}
+
+// Update runs fn under the exclusive file lock and writes everything it changed with a single save if it returns
+// nil. If fn returns an error nothing is written. The persister passed to fn is only valid until fn returns, and fn
+// must not use the IniPersister itself.
+func (i *IniPersister) Update(fn func(tx lizt.Persister) error) error {
+	return i.update(func(section *ini.Section) (bool, error) {
+		tx := &iniTx{ini: i, section: section}
+		err := fn(tx)
+		return tx.changed, err
+	})
+}
+
+// Close releases the lock file.
+func (i *IniPersister) Close() error {
+	return i.lock.Close()
+}
+
+// iniTx is the persister of one Update. It works on the staged copy of the pointers section.
+type iniTx struct {
+	ini     *IniPersister
+	section *ini.Section
+	changed bool
+}
+
+func (t *iniTx) Set(key string, value uint64) error {
+	t.section.Key(key).SetValue(fmt.Sprintf("%d", value))
+	t.changed = true
+	return nil
+}
+
+func (t *iniTx) Get(key string) (uint64, error) {
+	return t.ini.value(t.section, key)
+}
+
+func (t *iniTx) Increment(key string, delta uint64) (uint64, error) {
+	cur, err := t.ini.value(t.section, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	return cur + delta, t.Set(key, cur+delta)
+}
+
+func (t *iniTx) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	cur, err := t.ini.value(t.section, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	if cur != old {
+		return false, nil
+	}
+	return true, t.Set(key, new)
+}
+
+func (t *iniTx) Delete(key string) error {
+	if t.section.HasKey(key) {
+		t.section.DeleteKey(key)
+		t.changed = true
+	}
+	return nil
 }
@@ -1,7 +1,9 @@
 package persist
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -35,7 +37,10 @@ func TestNewIniPersister(t *testing.T) {
 		t.Errorf("Expected file to contain test = 1")
 	}
 
+	_ = persist.Close()
 	_ = os.Remove(path)
+	_ = os.Remove(path + ".bak")
+	_ = os.Remove(path + ".lock")
 }
 
 func TestNewIniPersister_Builder(t *testing.T) {
@@ -63,5 +68,98 @@ func TestNewIniPersister_Builder(t *testing.T) {
 		t.Errorf("Expected file to contain test = 1")
 	}
 
+	_ = persist.Close()
 	_ = os.Remove(path)
+	_ = os.Remove(path + ".bak")
+	_ = os.Remove(path + ".lock")
+}
+
+func TestNewIniPersister_Backup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.ini")
+
+	persist, err := NewIniPersister(path)
+	if err != nil {
+		t.Fatalf("NewIniPersister() error = %v", err)
+	}
+	_ = persist.Set("test", 1)
+	_ = persist.Set("test", 2)
+
+	backup, err := lizt.ReadFileToString(path + ".bak")
+	if err != nil {
+		t.Fatalf("ReadFileToString() error = %v", err)
+	}
+	if !strings.Contains(backup, "test = 1") {
+		t.Errorf("Expected the backup to contain the previous value, got %s", backup)
+	}
+}
+
+func TestNewIniPersister_CorruptFallsBackToBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.ini")
+
+	persist, err := NewIniPersister(path)
+	if err != nil {
+		t.Fatalf("NewIniPersister() error = %v", err)
+	}
+	_ = persist.Set("test", 10)
+	_ = persist.Set("test", 11)
+
+	// truncate the file mid-value, like a crash during a non-atomic write
+	data, _ := os.ReadFile(path)
+	if err = os.WriteFile(path, data[:len(data)-2], 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	persist, err = NewIniPersister(path)
+	if err != nil {
+		t.Fatalf("NewIniPersister() error = %v", err)
+	}
+	if val, err := persist.Get("test"); err != nil || val != 10 {
+		t.Errorf("Get() = %d, %v, want %d from the backup", val, err, 10)
+	}
+
+	// the backup is still intact after the repaired file is saved again
+	_ = persist.Set("test", 12)
+	if _, err = loadIni(path + ".bak"); err != nil {
+		t.Errorf("Expected a valid backup, got error = %v", err)
+	}
+}
+
+func TestNewIniPersister_CorruptWithoutBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.ini")
+	if err := os.WriteFile(path, []byte("; lizt crc32=00000000\n[pointers]\ntest = 1\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err := NewIniPersister(path); !errors.Is(err, lizt.ErrCorruptState) {
+		t.Errorf("wanted ErrCorruptState, got error = %v", err)
+	}
+	if x, _ := lizt.ReadFileToString(path); !strings.Contains(x, "test = 1") {
+		t.Errorf("Expected the corrupt file to be left alone, got %s", x)
+	}
+}
+
+func TestNewIniPersister_Legacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.ini")
+	if err := os.WriteFile(path, []byte("[pointers]\ntest = 4\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	persist, err := NewIniPersister(path)
+	if err != nil {
+		t.Fatalf("NewIniPersister() error = %v", err)
+	}
+	if val, err := persist.Get("test"); err != nil || val != 4 {
+		t.Errorf("Get() = %d, %v, want %d", val, err, 4)
+	}
+}
+
+func TestNewIniPersister_BadValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.ini")
+	if err := os.WriteFile(path, []byte("[pointers]\ntest = abc\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err := NewIniPersister(path); !errors.Is(err, lizt.ErrCorruptState) {
+		t.Errorf("wanted ErrCorruptState, got error = %v", err)
+	}
 }
@@ -0,0 +1,67 @@
+package persist
+
+import (
+	"encoding/json"
+
+	"git.faze.center/netr/lizt"
+)
+
+// JSONPersister is a persister that uses a JSON file. Every Set rewrites the file atomically, while Update writes
+// several keys with one rewrite.
+type JSONPersister struct {
+	root *Namespace
+}
+
+// NewJSONPersister creates a new thread-safe JSONPersister instance using the given path. The file is created if it
+// doesn't exist.
+func NewJSONPersister(path string) (*JSONPersister, error) {
+	doc, err := newDocPersister(path, func(v interface{}) ([]byte, error) {
+		return json.MarshalIndent(v, "", "  ")
+	}, json.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONPersister{root: &Namespace{doc: doc}}, nil
+}
+
+// Namespace returns the top-level namespace with the given name, e.g. one per Manager.
+func (p *JSONPersister) Namespace(name string) *Namespace {
+	return p.root.Namespace(name)
+}
+
+// Set sets the value of a key
+func (p *JSONPersister) Set(key string, value uint64) error {
+	return p.root.Set(key, value)
+}
+
+// Get gets the value of a key
+func (p *JSONPersister) Get(key string) (uint64, error) {
+	return p.root.Get(key)
+}
+
+// Increment adds delta to the value of a key, a missing key being 0, and returns the new value.
+func (p *JSONPersister) Increment(key string, delta uint64) (uint64, error) {
+	return p.root.Increment(key, delta)
+}
+
+// CompareAndSwap sets the value of a key to new if it's old, a missing key being 0. It reports whether it did.
+func (p *JSONPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	return p.root.CompareAndSwap(key, old, new)
+}
+
+// Delete deletes a key
+func (p *JSONPersister) Delete(key string) error {
+	return p.root.Delete(key)
+}
+
+// Update runs fn under the exclusive file lock and writes everything it changed with a single save. See
+// Namespace.Update.
+func (p *JSONPersister) Update(fn func(tx lizt.Persister) error) error {
+	return p.root.Update(fn)
+}
+
+// Close releases the lock file.
+func (p *JSONPersister) Close() error {
+	return p.root.doc.lock.Close()
+}
@@ -0,0 +1,42 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestNewJSONPersister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.json")
+
+	persist, err := NewJSONPersister(path)
+	if err != nil {
+		t.Fatalf("NewJSONPersister() error = %v", err)
+	}
+	if err = persist.Namespace("manager").Set("test", 1); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"manager": {`) || !strings.Contains(string(data), `"test": 1`) {
+		t.Errorf("Expected file to contain the namespaced key, got %s", data)
+	}
+}
+
+func TestNewJSONPersister_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.json")
+	if err := os.WriteFile(path, []byte(`{"pointers": {"test": 1`), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err := NewJSONPersister(path); !errors.Is(err, lizt.ErrCorruptState) {
+		t.Errorf("wanted ErrCorruptState, got error = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package persist
+
+import (
+	"fmt"
+	"os"
+)
+
+// fileLock is an advisory lock on "<path>.lock", shared by every process that uses the persister file at path. The
+// persister file itself can't be locked, since every save replaces it. Locks from the same process also exclude
+// each other, as long as they come from separate fileLocks.
+type fileLock struct {
+	file *os.File
+}
+
+func openFileLock(path string) (*fileLock, error) {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %s -> %w", path, err)
+	}
+	return &fileLock{file: file}, nil
+}
+
+// Lock takes the lock exclusively, for a read-modify-write.
+func (l *fileLock) Lock() error {
+	if err := lockFile(l.file, true); err != nil {
+		return fmt.Errorf("failed to lock: %s -> %w", l.file.Name(), err)
+	}
+	return nil
+}
+
+// RLock takes the lock shared, for a read.
+func (l *fileLock) RLock() error {
+	if err := lockFile(l.file, false); err != nil {
+		return fmt.Errorf("failed to lock: %s -> %w", l.file.Name(), err)
+	}
+	return nil
+}
+
+// Unlock releases the lock.
+func (l *fileLock) Unlock() {
+	_ = unlockFile(l.file)
+}
+
+// Close releases the lock file.
+func (l *fileLock) Close() error {
+	return l.file.Close()
+}
+
+// fileStamp tells whether a file changed since it was last read. Saves replace the file, so another process's
+// save shows up as a different file even if the size and modification time happen to match.
+type fileStamp struct {
+	info os.FileInfo
+}
+
+// changed reports whether the file at path differs from the stamped one. A missing file counts as changed only if
+// one was stamped.
+func (s *fileStamp) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return s.info != nil
+	}
+	return s.info == nil || !os.SameFile(s.info, info) || !s.info.ModTime().Equal(info.ModTime()) ||
+		s.info.Size() != info.Size()
+}
+
+// stamp records the current file at path.
+func (s *fileStamp) stamp(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		s.info = nil
+		return
+	}
+	s.info = info
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package persist
+
+import "os"
+
+// lockFile is a no-op where flock isn't available, so persister files can't be shared between processes there.
+func lockFile(*os.File, bool) error {
+	return nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
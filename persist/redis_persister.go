@@ -0,0 +1,129 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"git.faze.center/netr/lizt"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNoClient = errors.New("no redis client")
+
+// redisCompareAndSwap sets KEYS[1] to ARGV[2] if it's ARGV[1], a missing key being 0, with a TTL of ARGV[3]
+// milliseconds unless it's 0.
+var redisCompareAndSwap = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1]) or '0'
+if cur ~= ARGV[1] then
+	return 0
+end
+if ARGV[3] ~= '0' then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// RedisPersister is a persister that uses Redis, so several hosts can share pointers. Every key is stored as
+// "<Prefix><key>", and every write refreshes the TTL of the key if there is one. Increment is INCRBY, so claiming a
+// block of lines is a single atomic command, but values above math.MaxInt64 can't be incremented.
+type RedisPersister struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// RedisPersisterConfig is the config for a redis persister. The Prefix keeps the keys apart from other data in the
+// same database, e.g. "lizt:". With a TTL, keys that aren't written for that long expire.
+type RedisPersisterConfig struct {
+	Client redis.UniversalClient
+	Prefix string
+	TTL    time.Duration
+}
+
+// NewRedisPersister returns a new redis persister. It doesn't connect until it's first used.
+func NewRedisPersister(cfg RedisPersisterConfig) (*RedisPersister, error) {
+	if cfg.Client == nil {
+		return nil, fmt.Errorf("redis persister -> %w", ErrNoClient)
+	}
+	return &RedisPersister{client: cfg.Client, prefix: cfg.Prefix, ttl: cfg.TTL}, nil
+}
+
+// Namespace returns a persister whose keys are prefixed with "<name>:" after the prefix of r.
+func (r *RedisPersister) Namespace(name string) *RedisPersister {
+	return &RedisPersister{client: r.client, prefix: r.prefix + name + ":", ttl: r.ttl}
+}
+
+// Set sets the value of a key
+func (r *RedisPersister) Set(key string, value uint64) error {
+	return r.SetWithTTL(key, value, r.ttl)
+}
+
+// SetWithTTL sets the value of a key that expires after ttl, or never if ttl is 0.
+func (r *RedisPersister) SetWithTTL(key string, value uint64, ttl time.Duration) error {
+	if err := r.client.Set(context.Background(), r.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis: key: %s -> %w", key, err)
+	}
+	return nil
+}
+
+// Get gets the value of a key
+func (r *RedisPersister) Get(key string) (uint64, error) {
+	val, err := r.client.Get(context.Background(), r.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("redis: key: %s -> %w", key, err)
+	}
+
+	n, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("redis: key: %s -> %w", key, lizt.ErrCorruptState)
+	}
+	return n, nil
+}
+
+// Delete deletes a key
+func (r *RedisPersister) Delete(key string) error {
+	if err := r.client.Del(context.Background(), r.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis: key: %s -> %w", key, err)
+	}
+	return nil
+}
+
+// Increment adds delta to the value of a key with INCRBY, a missing key being 0, and returns the new value.
+func (r *RedisPersister) Increment(key string, delta uint64) (uint64, error) {
+	if delta > math.MaxInt64 {
+		return 0, fmt.Errorf("redis: key: %s: delta %d is above math.MaxInt64", key, delta)
+	}
+	ctx := context.Background()
+
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, r.prefix+key, int64(delta))
+		if r.ttl > 0 {
+			pipe.PExpire(ctx, r.prefix+key, r.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("redis: key: %s -> %w", key, err)
+	}
+	return uint64(incr.Val()), nil
+}
+
+// CompareAndSwap sets the value of a key to new if it's old, a missing key being 0. It reports whether it did.
+func (r *RedisPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	swapped, err := redisCompareAndSwap.Run(context.Background(), r.client, []string{r.prefix + key},
+		strconv.FormatUint(old, 10), strconv.FormatUint(new, 10), r.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("redis: key: %s -> %w", key, err)
+	}
+	return swapped == 1, nil
+}
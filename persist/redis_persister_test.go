@@ -0,0 +1,207 @@
+package persist
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"git.faze.center/netr/lizt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	testRedis     *miniredis.Miniredis
+	testRedisOnce sync.Once
+)
+
+// testRedisClient returns a client of an in-process redis server that's shared by every test.
+func testRedisClient(t *testing.T) *redis.Client {
+	testRedisOnce.Do(func() {
+		testRedis = miniredis.NewMiniRedis()
+		if err := testRedis.Start(); err != nil {
+			t.Fatalf("miniredis.Start() error = %v", err)
+		}
+	})
+	client := redis.NewClient(&redis.Options{Addr: testRedis.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestRedisPersister_Prefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	p, err := NewRedisPersister(RedisPersisterConfig{Client: client, Prefix: "lizt:"})
+	if err != nil {
+		t.Fatalf("NewRedisPersister() error = %v", err)
+	}
+
+	_ = p.Set("list", 3)
+	_ = p.Namespace("manager").Set("list", 4)
+
+	if got, _ := mr.Get("lizt:list"); got != "3" {
+		t.Errorf("expected %s, got %s", "3", got)
+	}
+	if got, _ := mr.Get("lizt:manager:list"); got != "4" {
+		t.Errorf("expected %s, got %s", "4", got)
+	}
+}
+
+func TestRedisPersister_TTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	p, err := NewRedisPersister(RedisPersisterConfig{Client: client, Prefix: "lizt:", TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("NewRedisPersister() error = %v", err)
+	}
+
+	_ = p.Set("list", 3)
+	_, _ = p.Increment("claims", 10)
+	_, _ = p.CompareAndSwap("cas", 0, 1)
+	for _, key := range []string{"lizt:list", "lizt:claims", "lizt:cas"} {
+		if ttl := mr.TTL(key); ttl != time.Minute {
+			t.Errorf("%s: expected a TTL of %v, got %v", key, time.Minute, ttl)
+		}
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if _, err := p.Get("list"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted ErrNotFound after the TTL, got error = %v", err)
+	}
+}
+
+func TestRedisPersister_SetWithTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	p, err := NewRedisPersister(RedisPersisterConfig{Client: client, Prefix: "lizt:"})
+	if err != nil {
+		t.Fatalf("NewRedisPersister() error = %v", err)
+	}
+
+	_ = p.Set("list", 1)
+	_ = p.SetWithTTL("lease", 1, time.Second)
+	mr.FastForward(time.Minute)
+
+	if _, err := p.Get("list"); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if _, err := p.Get("lease"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted ErrNotFound after the TTL, got error = %v", err)
+	}
+}
+
+func TestRedisPersister_Claims(t *testing.T) {
+	p, err := NewRedisPersister(RedisPersisterConfig{Client: testRedisClient(t), Prefix: t.Name() + ":"})
+	if err != nil {
+		t.Fatalf("NewRedisPersister() error = %v", err)
+	}
+
+	// workers claim blocks of 100 lines; no two get the same block
+	var mu sync.Mutex
+	starts := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			end, err := p.Increment("claims", 100)
+			if err != nil {
+				t.Errorf("Increment() error = %v", err)
+				return
+			}
+			mu.Lock()
+			starts[end-100] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(starts) != 10 {
+		t.Errorf("expected %d distinct blocks, got %d", 10, len(starts))
+	}
+}
+
+func TestRedisPersister_Corrupt(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	p, err := NewRedisPersister(RedisPersisterConfig{Client: client, Prefix: "lizt:"})
+	if err != nil {
+		t.Fatalf("NewRedisPersister() error = %v", err)
+	}
+	_ = mr.Set("lizt:list", "abc")
+
+	if _, err := p.Get("list"); !errors.Is(err, lizt.ErrCorruptState) {
+		t.Errorf("wanted ErrCorruptState, got error = %v", err)
+	}
+}
+
+func TestRedisPersister_NoClient(t *testing.T) {
+	if _, err := NewRedisPersister(RedisPersisterConfig{}); !errors.Is(err, ErrNoClient) {
+		t.Errorf("wanted ErrNoClient, got error = %v", err)
+	}
+}
+
+func TestRedisPersister_ClaimingIterator(t *testing.T) {
+	mr := miniredis.RunT(t)
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = strconv.Itoa(i)
+	}
+
+	// every worker has a client of its own, like a process on another host
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+		p, _ := NewRedisPersister(RedisPersisterConfig{Client: client, Prefix: "lizt:"})
+		ci, err := lizt.NewClaimingIterator(lizt.ClaimingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator("numbers", lines, false),
+			Persister:   p,
+			RangeSize:   50,
+		})
+		if err != nil {
+			t.Fatalf("NewClaimingIterator() error = %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				next, err := ci.Next(20)
+				mu.Lock()
+				for _, line := range next {
+					seen[line]++
+				}
+				mu.Unlock()
+				if err != nil {
+					if !errors.Is(err, lizt.ErrNoMoreLines) {
+						t.Errorf("Next() error = %v", err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != len(lines) {
+		t.Errorf("expected %d lines, got %d", len(lines), len(seen))
+	}
+	for line, n := range seen {
+		if n != 1 {
+			t.Errorf("line %s served %d times", line, n)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package persist
+
+import (
+	"git.faze.center/netr/lizt"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLPersister is a persister that uses a YAML file. Every Set rewrites the file atomically, while Update writes
+// several keys with one rewrite.
+type YAMLPersister struct {
+	root *Namespace
+}
+
+// NewYAMLPersister creates a new thread-safe YAMLPersister instance using the given path. The file is created if it
+// doesn't exist.
+func NewYAMLPersister(path string) (*YAMLPersister, error) {
+	doc, err := newDocPersister(path, yaml.Marshal, yaml.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &YAMLPersister{root: &Namespace{doc: doc}}, nil
+}
+
+// Namespace returns the top-level namespace with the given name, e.g. one per Manager.
+func (p *YAMLPersister) Namespace(name string) *Namespace {
+	return p.root.Namespace(name)
+}
+
+// Set sets the value of a key
+func (p *YAMLPersister) Set(key string, value uint64) error {
+	return p.root.Set(key, value)
+}
+
+// Get gets the value of a key
+func (p *YAMLPersister) Get(key string) (uint64, error) {
+	return p.root.Get(key)
+}
+
+// Increment adds delta to the value of a key, a missing key being 0, and returns the new value.
+func (p *YAMLPersister) Increment(key string, delta uint64) (uint64, error) {
+	return p.root.Increment(key, delta)
+}
+
+// CompareAndSwap sets the value of a key to new if it's old, a missing key being 0. It reports whether it did.
+func (p *YAMLPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	return p.root.CompareAndSwap(key, old, new)
+}
+
+// Delete deletes a key
+func (p *YAMLPersister) Delete(key string) error {
+	return p.root.Delete(key)
+}
+
+// Update runs fn under the exclusive file lock and writes everything it changed with a single save. See
+// Namespace.Update.
+func (p *YAMLPersister) Update(fn func(tx lizt.Persister) error) error {
+	return p.root.Update(fn)
+}
+
+// Close releases the lock file.
+func (p *YAMLPersister) Close() error {
+	return p.root.doc.lock.Close()
+}
@@ -0,0 +1,45 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestNewYAMLPersister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.yaml")
+
+	persist, err := NewYAMLPersister(path)
+	if err != nil {
+		t.Fatalf("NewYAMLPersister() error = %v", err)
+	}
+	if err = persist.Set("test", 1); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+	if err = persist.Namespace("manager").Set("test", 2); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(data), "test: 1") || !strings.Contains(string(data), "manager:") {
+		t.Errorf("Expected file to contain both keys, got %s", data)
+	}
+}
+
+func TestNewYAMLPersister_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pointers.yaml")
+	if err := os.WriteFile(path, []byte("pointers: [test"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err := NewYAMLPersister(path); !errors.Is(err, lizt.ErrCorruptState) {
+		t.Errorf("wanted ErrCorruptState, got error = %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package lizt_test
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
@@ -169,3 +170,110 @@ func (i *InMemoryPersister) Get(key string) (uint64, error) {
 
 	return 0, ErrNotFound
 }
+
+func (i *InMemoryPersister) Increment(key string, delta uint64) (uint64, error) {
+	i.pointers[key] += delta
+	return i.pointers[key], nil
+}
+
+func (i *InMemoryPersister) Delete(key string) error {
+	delete(i.pointers, key)
+	return nil
+}
+
+func (i *InMemoryPersister) CompareAndSwap(key string, old, new uint64) (bool, error) {
+	if i.pointers[key] != old {
+		return false, nil
+	}
+	i.pointers[key] = new
+	return true, nil
+}
+
+func TestPersistentIterator_NextContext_Canceled(t *testing.T) {
+	numbers := []string{"1", "2", "3", "4", "5"}
+	mem := NewInMemoryPersister()
+
+	p, err := lizt.NewPersistentIterator(
+		lizt.PersistentIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, numbers, false),
+			Persister:   mem,
+		},
+	)
+	if err != nil {
+		t.Errorf("NewPersistentIterator expected no error, got %v", err)
+	}
+
+	if _, err = p.NextContext(context.Background(), 2); err != nil {
+		t.Errorf("NextContext expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = p.NextContext(ctx, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	if mem.pointers[nameNumbers] != 2 {
+		t.Errorf("Expected %d, got %d", 2, mem.pointers[nameNumbers])
+	}
+}
+
+// corruptPersister is a persister whose stored state can't be read.
+type corruptPersister struct{}
+
+func (corruptPersister) Set(string, uint64) error { return nil }
+
+func (corruptPersister) Get(string) (uint64, error) { return 0, lizt.ErrCorruptState }
+
+func (corruptPersister) Increment(string, uint64) (uint64, error) { return 0, lizt.ErrCorruptState }
+
+func (corruptPersister) CompareAndSwap(string, uint64, uint64) (bool, error) {
+	return false, lizt.ErrCorruptState
+}
+
+func TestPersistentIterator_CorruptState(t *testing.T) {
+	_, err := lizt.NewPersistentIterator(lizt.PersistentIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2"}, false),
+		Persister:   corruptPersister{},
+	})
+	if !errors.Is(err, lizt.ErrCorruptState) {
+		t.Errorf("wanted ErrCorruptState, got error = %v", err)
+	}
+}
+
+// TxInMemoryPersister is an InMemoryPersister with transactions. It counts the transactions it commits.
+type TxInMemoryPersister struct {
+	*InMemoryPersister
+	commits int
+}
+
+func (tx *TxInMemoryPersister) Update(fn func(lizt.Persister) error) error {
+	staged := NewInMemoryPersister()
+	for k, v := range tx.pointers {
+		staged.pointers[k] = v
+	}
+	if err := fn(staged); err != nil {
+		return err
+	}
+	tx.pointers = staged.pointers
+	tx.commits++
+	return nil
+}
+
+func TestPersistentIterator_TxPersister(t *testing.T) {
+	mem := &TxInMemoryPersister{InMemoryPersister: NewInMemoryPersister()}
+
+	iter := lizt.B().SliceNamed("letters", []string{"a", "b", "c"}, false).PersistTo(mem).MustBuildWithSeeds(2, []string{"s1"})
+	iter.MustNext(3)
+
+	if mem.commits != 1 {
+		t.Errorf("expected %d transaction, got %d", 1, mem.commits)
+	}
+	for key, want := range map[string]uint64{"letters": 1, "letters.seed.planted": 2, "letters.seed.pointer": 1} {
+		if mem.pointers[key] != want {
+			t.Errorf("%s: expected %d, got %d", key, want, mem.pointers[key])
+		}
+	}
+}
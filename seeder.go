@@ -1,10 +1,16 @@
 package lizt
 
 import (
+	"context"
 	"fmt"
 	"sync/atomic"
 )
 
+const (
+	stateSeedPlanted = "seed.planted"
+	stateSeedPointer = "seed.pointer"
+)
+
 // SeedingIterator is an iterator that reads from a slice.
 type SeedingIterator struct {
 	Seeder
@@ -47,9 +53,14 @@ func (si *SeedingIterator) inc() {
 
 // Next returns the next line from the iterator and will automatically seed every PlantEvery() lines.
 func (si *SeedingIterator) Next(count int) ([]string, error) {
-	lines, _, err := si.nextSeed(count)
+	return si.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines from the iterator, planting seeds as Next does. It stops early if ctx is done.
+func (si *SeedingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	lines, _, err := si.nextSeed(ctx, count)
 	if err != nil {
-		return nil, err
+		return lines, err
 	}
 
 	return lines, nil
@@ -58,25 +69,35 @@ func (si *SeedingIterator) Next(count int) ([]string, error) {
 // NextSeed returns the next line from the iterator and will automatically seed every PlantEvery() lines.
 // The difference from the interface Next() is that this returns a bool indicating if a seed was planted.
 func (si *SeedingIterator) NextSeed(count int) ([]string, bool, error) {
-	return si.nextSeed(count)
+	return si.nextSeed(context.Background(), count)
 }
 
-func (si *SeedingIterator) nextSeed(count int) ([]string, bool, error) {
+func (si *SeedingIterator) nextSeed(ctx context.Context, count int) ([]string, bool, error) {
 	var lines []string
 	seeded := false
 	for i := 0; i < count; i++ {
+		if err := contextErr(ctx, si.Name()); err != nil {
+			return lines, seeded, err
+		}
+
 		sent := si.Pointer() + uint64(si.Planted())
 		if sent%uint64(si.PlantEvery()) == 0 {
-			seed, err := si.seedIter.Next(1)
+			seed, err := si.seedIter.NextContext(ctx, 1)
 			if err != nil {
+				if ctx.Err() != nil {
+					return lines, seeded, contextErr(ctx, si.Name())
+				}
 				return nil, seeded, fmt.Errorf("seed iter next: %w", err)
 			}
 			seeded = true
 			si.inc()
 			lines = append(lines, seed[0])
 		} else {
-			next, err := si.PointerIterator.Next(1)
+			next, err := si.PointerIterator.NextContext(ctx, 1)
 			if err != nil {
+				if ctx.Err() != nil {
+					return append(lines, next...), seeded, contextErr(ctx, si.Name())
+				}
 				if len(lines) == 0 {
 					return nil, seeded, fmt.Errorf("file: %s -> %w", si.Name(), err)
 				}
@@ -88,3 +109,27 @@ func (si *SeedingIterator) nextSeed(count int) ([]string, bool, error) {
 
 	return lines, seeded, nil
 }
+
+// State returns the number of seeds planted and the pointer of the seed iterator, so a restart plants the same
+// seeds at the same places.
+func (si *SeedingIterator) State() map[string]uint64 {
+	return map[string]uint64{
+		stateSeedPlanted: uint64(si.Planted()),
+		stateSeedPointer: si.seedIter.Pointer(),
+	}
+}
+
+// SetState restores the number of seeds planted and the pointer of the seed iterator.
+func (si *SeedingIterator) SetState(state map[string]uint64) {
+	if planted, ok := state[stateSeedPlanted]; ok {
+		si.totalPlanted.Store(int64(planted))
+	}
+	if pointer, ok := state[stateSeedPointer]; ok {
+		si.seedIter.SetPointer(pointer)
+	}
+}
+
+// Unwrap returns the wrapped iterator.
+func (si *SeedingIterator) Unwrap() PointerIterator {
+	return si.PointerIterator
+}
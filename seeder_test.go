@@ -1,6 +1,9 @@
 package lizt_test
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"git.faze.center/netr/lizt"
@@ -116,3 +119,41 @@ func TestSeeder_NextSeed(t *testing.T) {
 		}
 	}
 }
+
+func TestSeeder_NextContext_Canceled(t *testing.T) {
+	seed := lizt.NewSeedingIterator(
+		lizt.SeedingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, []string{"1", "2"}, true),
+			SeedIter:    lizt.NewSliceIterator("seeds", []string{"seeder1"}, true),
+			PlantEvery:  2,
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := seed.NextContext(ctx, 4)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if seed.Planted() != 0 {
+		t.Errorf("Expected no seeds planted, got %d", seed.Planted())
+	}
+}
+
+func TestSeeder_State_Persisted(t *testing.T) {
+	mem := NewInMemoryPersister()
+	lines := []string{"a", "b", "c", "d", "e"}
+	seeds := []string{"s1", "s2", "s3"}
+
+	first := lizt.B().SliceNamed("letters", lines, false).PersistTo(mem).MustBuildWithSeeds(2, seeds)
+	if next := first.MustNext(4); !reflect.DeepEqual(next, []string{"s1", "a", "s2", "b"}) {
+		t.Errorf("expected %v, got %v", []string{"s1", "a", "s2", "b"}, next)
+	}
+
+	// a restart continues with the next seed instead of planting the first one again
+	second := lizt.B().SliceNamed("letters", lines, false).PersistTo(mem).MustBuildWithSeeds(2, seeds)
+	if next := second.MustNext(2); !reflect.DeepEqual(next, []string{"s3", "c"}) {
+		t.Errorf("expected %v, got %v", []string{"s3", "c"}, next)
+	}
+}
@@ -0,0 +1,228 @@
+package lizt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrInvalidShard = errors.New("invalid shard")
+
+// ShardMode selects the lines of a shard.
+type ShardMode int
+
+const (
+	// ShardModulo serves the lines whose index % Count == Index.
+	ShardModulo ShardMode = iota
+	// ShardBlocks serves the Index-th of Count contiguous blocks. Their sizes differ by one line at most, the first
+	// total % Count blocks being the larger ones.
+	ShardBlocks
+)
+
+// ShardingIterator serves one shard of another iterator, so Count workers can split a list without coordinating.
+// The pointer is the position in the shard, and the name is "<name>.shard-<index>-of-<count>", so a
+// PersistentIterator persists every shard separately.
+//
+// A StreamIterator, bare or wrapped in iterators that keep its pointer, is read forward: the lines of other shards
+// are skipped without a seek, unless the stream is indexed and a checkpoint lies past them, in which case it seeks
+// instead of reading them. If a wrapper such as a FilterIterator drops a line of the shard, the line it reads in its
+// place belongs to another shard, so it's dropped too.
+type ShardingIterator struct {
+	PointerIterator
+	mode       ShardMode
+	index      uint64
+	count      uint64
+	start      uint64
+	n          uint64
+	pointer    uint64
+	roundRobin bool
+	mu         sync.Mutex
+}
+
+// ShardingIteratorConfig is the config for a sharding iterator. Index is the shard, from 0 to Count-1.
+type ShardingIteratorConfig struct {
+	PointerIter PointerIterator
+	Index       int
+	Count       int
+	Mode        ShardMode
+	RoundRobin  bool
+}
+
+// NewShardingIterator returns a new sharding iterator. It returns ErrInvalidShard unless 0 <= Index < Count.
+func NewShardingIterator(cfg ShardingIteratorConfig) (*ShardingIterator, error) {
+	if cfg.Count <= 0 || cfg.Index < 0 || cfg.Index >= cfg.Count {
+		return nil, fmt.Errorf("shard: name: %s: %d of %d -> %w", cfg.PointerIter.Name(), cfg.Index, cfg.Count, ErrInvalidShard)
+	}
+
+	shi := &ShardingIterator{
+		PointerIterator: cfg.PointerIter,
+		mode:            cfg.Mode,
+		index:           uint64(cfg.Index),
+		count:           uint64(cfg.Count),
+		roundRobin:      cfg.RoundRobin,
+	}
+
+	total := uint64(cfg.PointerIter.Len())
+	switch cfg.Mode {
+	case ShardBlocks:
+		base, rem := total/shi.count, total%shi.count
+		shi.start = shi.index*base + min64(shi.index, rem)
+		shi.n = base
+		if shi.index < rem {
+			shi.n++
+		}
+	default:
+		if shi.index < total {
+			shi.n = (total - shi.index + shi.count - 1) / shi.count
+		}
+	}
+	return shi, nil
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// line returns the index of the p-th line of the shard in the wrapped iterator.
+func (shi *ShardingIterator) line(p uint64) uint64 {
+	if shi.mode == ShardBlocks {
+		return shi.start + p
+	}
+	return p*shi.count + shi.index
+}
+
+// moveTo moves the wrapped iterator to line p, reading a stream forward rather than seeking where it can, also
+// through wrappers such as Map or Filter.
+func (shi *ShardingIterator) moveTo(p uint64) {
+	cur := shi.PointerIterator.Pointer()
+	if cur == p {
+		return
+	}
+	if p > cur {
+		advanceTo(shi.PointerIterator, p)
+		return
+	}
+	shi.PointerIterator.SetPointer(p)
+}
+
+// Next returns the next lines, of a given count, of the shard.
+func (shi *ShardingIterator) Next(count int) ([]string, error) {
+	return shi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, of the shard. It stops early if ctx is done.
+func (shi *ShardingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	var lines []string
+	var skipped uint64
+	for len(lines) < count {
+		if err := contextErr(ctx, shi.Name()); err != nil {
+			return lines, err
+		}
+
+		if shi.pointer >= shi.n {
+			if !shi.roundRobin || shi.n == 0 || skipped >= shi.n {
+				if len(lines) == 0 {
+					return nil, fmt.Errorf("shard: name: %s -> %w", shi.Name(), ErrNoMoreLines)
+				}
+				return lines, nil
+			}
+			shi.pointer = 0
+		}
+
+		line := shi.line(shi.pointer)
+		shi.moveTo(line)
+		next, err := shi.PointerIterator.NextContext(ctx, 1)
+		if err != nil && !errors.Is(err, ErrNoMoreLines) {
+			if ctx.Err() != nil {
+				return lines, contextErr(ctx, shi.Name())
+			}
+			return nil, fmt.Errorf("shard: name: %s -> %w", shi.Name(), err)
+		}
+		shi.pointer++
+
+		// a wrapper that skips lines, such as Filter, dropped this one and returned a line of another shard
+		if p := shi.PointerIterator.Pointer(); err != nil || len(next) == 0 || (p != line && p != line+1) {
+			skipped++
+			continue
+		}
+		skipped = 0
+		lines = append(lines, next[0])
+	}
+	return lines, nil
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (shi *ShardingIterator) MustNext(count int) []string {
+	lines, err := shi.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (shi *ShardingIterator) NextOne() (string, error) {
+	lines, err := shi.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (shi *ShardingIterator) MustNextOne() string {
+	line, err := shi.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// Name returns the name of the shard.
+func (shi *ShardingIterator) Name() string {
+	return fmt.Sprintf("%s.shard-%d-of-%d", shi.PointerIterator.Name(), shi.index, shi.count)
+}
+
+// Len returns the number of lines in the shard.
+func (shi *ShardingIterator) Len() int {
+	return int(shi.n)
+}
+
+// Pointer returns the position in the shard.
+func (shi *ShardingIterator) Pointer() uint64 {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	return shi.pointer
+}
+
+// SetPointer sets the position in the shard.
+func (shi *ShardingIterator) SetPointer(p uint64) {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	if p > shi.n {
+		p = 0
+	}
+	shi.pointer = p
+}
+
+// Inc skips a line of the shard.
+func (shi *ShardingIterator) Inc() {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	shi.pointer++
+}
+
+// Unwrap returns the wrapped iterator.
+func (shi *ShardingIterator) Unwrap() PointerIterator {
+	return shi.PointerIterator
+}
@@ -0,0 +1,241 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+// drainShard reads the whole shard.
+func drainShard(t *testing.T, shi lizt.PointerIterator) []string {
+	t.Helper()
+
+	var lines []string
+	for {
+		next, err := shi.Next(3)
+		if errors.Is(err, lizt.ErrNoMoreLines) {
+			return lines
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		lines = append(lines, next...)
+	}
+}
+
+func TestShardingIterator_Modulo(t *testing.T) {
+	expected := [][]string{
+		{"0", "3", "6", "9"},
+		{"1", "4", "7"},
+		{"2", "5", "8"},
+	}
+
+	var all []string
+	for i, want := range expected {
+		shi := lizt.B().SliceNamed(nameNumbers, numberedLines(10), false).Shard(i, 3).MustBuild()
+		if shi.Len() != len(want) {
+			t.Errorf("shard %d: expected Len() %d, got %d", i, len(want), shi.Len())
+		}
+
+		got := drainShard(t, shi)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("shard %d: expected %v, got %v", i, want, got)
+		}
+		all = append(all, got...)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		a, _ := strconv.Atoi(all[i])
+		b, _ := strconv.Atoi(all[j])
+		return a < b
+	})
+	if !reflect.DeepEqual(all, numberedLines(10)) {
+		t.Errorf("expected the shards to cover every line once, got %v", all)
+	}
+}
+
+func TestShardingIterator_Blocks(t *testing.T) {
+	tests := []struct {
+		lines    int
+		expected [][]string
+	}{
+		{10, [][]string{{"0", "1", "2", "3"}, {"4", "5", "6"}, {"7", "8", "9"}}},
+		{4, [][]string{{"0", "1"}, {"2"}, {"3"}}},
+	}
+
+	for _, tt := range tests {
+		for i, want := range tt.expected {
+			shi := lizt.B().SliceNamed(nameNumbers, numberedLines(tt.lines), false).ShardBlocks(i, 3).MustBuild()
+			if shi.Len() != len(want) {
+				t.Errorf("%d lines, shard %d: expected Len() %d, got %d", tt.lines, i, len(want), shi.Len())
+			}
+			got := drainShard(t, shi)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%d lines, shard %d: expected %v, got %v", tt.lines, i, want, got)
+			}
+		}
+	}
+}
+
+func TestShardingIterator_MoreShardsThanLines(t *testing.T) {
+	for _, mode := range []lizt.ShardMode{lizt.ShardModulo, lizt.ShardBlocks} {
+		shi, err := lizt.NewShardingIterator(lizt.ShardingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, numberedLines(2), false),
+			Index:       3,
+			Count:       4,
+			Mode:        mode,
+		})
+		if err != nil {
+			t.Fatalf("NewShardingIterator() error = %v", err)
+		}
+		if shi.Len() != 0 {
+			t.Errorf("mode %d: expected an empty shard, got Len() %d", mode, shi.Len())
+		}
+		if _, err = shi.Next(1); !errors.Is(err, lizt.ErrNoMoreLines) {
+			t.Errorf("mode %d: wanted ErrNoMoreLines, got error = %v", mode, err)
+		}
+	}
+}
+
+func TestShardingIterator_InvalidShard(t *testing.T) {
+	for _, shard := range [][2]int{{0, 0}, {3, 3}, {-1, 2}} {
+		_, err := lizt.NewShardingIterator(lizt.ShardingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, numberedLines(10), false),
+			Index:       shard[0],
+			Count:       shard[1],
+		})
+		if !errors.Is(err, lizt.ErrInvalidShard) {
+			t.Errorf("shard %d of %d: wanted ErrInvalidShard, got error = %v", shard[0], shard[1], err)
+		}
+	}
+}
+
+func TestShardingIterator_RoundRobin(t *testing.T) {
+	shi := lizt.B().SliceNamed(nameNumbers, numberedLines(10), false).ShardRR(1, 3).MustBuild()
+
+	expected := []string{"1", "4", "7", "1", "4"}
+	next := shi.MustNext(5)
+	if !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+	if shi.Pointer() != 2 {
+		t.Errorf("expected pointer to be %d, got %d", 2, shi.Pointer())
+	}
+}
+
+func TestShardingIterator_Filter_ShouldOnlyServeItsOwnLines(t *testing.T) {
+	keep := func(s string) bool { return s != "2" && s != "7" }
+	tests := []struct {
+		mode     lizt.ShardMode
+		expected [][]string
+	}{
+		{lizt.ShardModulo, [][]string{{"0", "4", "6"}, {"1", "3", "5"}}},
+		{lizt.ShardBlocks, [][]string{{"0", "1", "3"}, {"4", "5", "6"}}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.expected {
+			shi, err := lizt.NewShardingIterator(lizt.ShardingIteratorConfig{
+				PointerIter: lizt.B().SliceNamed(nameNumbers, numberedLines(8), false).Filter(keep).MustBuild(),
+				Index:       i,
+				Count:       2,
+				Mode:        tt.mode,
+			})
+			if err != nil {
+				t.Fatalf("NewShardingIterator() error = %v", err)
+			}
+			if got := drainShard(t, shi); !reflect.DeepEqual(got, want) {
+				t.Errorf("mode %d, shard %d: expected %v, got %v", tt.mode, i, want, got)
+			}
+		}
+	}
+
+	shi := lizt.B().SliceNamed(nameNumbers, numberedLines(8), false).Filter(keep).ShardRR(0, 2).MustBuild()
+	if next := shi.MustNext(4); !reflect.DeepEqual(next, []string{"0", "4", "6", "0"}) {
+		t.Errorf("expected %v, got %v", []string{"0", "4", "6", "0"}, next)
+	}
+
+	shi = lizt.B().SliceNamed(nameNumbers, numberedLines(8), false).Filter(func(string) bool { return false }).ShardRR(0, 2).MustBuild()
+	if _, err := shi.Next(1); !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestShardingIterator_PersistsPerShard(t *testing.T) {
+	mem := NewInMemoryPersister()
+	build := func(index int) lizt.PointerIterator {
+		return lizt.B().SliceNamed(nameNumbers, numberedLines(10), false).Shard(index, 3).PersistTo(mem).MustBuild()
+	}
+
+	first, second := build(0), build(1)
+	first.MustNext(2)
+	second.MustNext(1)
+
+	key0, key1 := nameNumbers+".shard-0-of-3", nameNumbers+".shard-1-of-3"
+	if mem.pointers[key0] != 2 || mem.pointers[key1] != 1 {
+		t.Errorf("expected shard pointers 2 and 1, got %v", mem.pointers)
+	}
+
+	resumed := build(0)
+	expected := []string{"6", "9"}
+	if next := resumed.MustNext(2); !reflect.DeepEqual(next, expected) {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+}
+
+func TestShardingIterator_Stream(t *testing.T) {
+	stride := lizt.IndexStride
+	lizt.IndexStride = 16
+	defer func() { lizt.IndexStride = stride }()
+
+	lines := numberedLines(1000)
+	path := filepath.Join(t.TempDir(), "shards.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	// the plain stream runs first, before the index is built next to the file
+	for _, name := range []string{"plain", "indexed", "mapped"} {
+		newStream := func() (*lizt.StreamIterator, error) { return lizt.NewStreamIterator(path, false) }
+		if name != "plain" {
+			newStream = func() (*lizt.StreamIterator, error) { return lizt.NewIndexedStreamIterator(path, false) }
+		}
+
+		for _, count := range []int{3, 40} {
+			for _, mode := range []lizt.ShardMode{lizt.ShardModulo, lizt.ShardBlocks} {
+				for _, index := range []int{0, count - 1} {
+					stream, err := newStream()
+					if err != nil {
+						t.Fatalf("%s: error = %v", name, err)
+					}
+					var iter lizt.PointerIterator = stream
+					if name == "mapped" {
+						iter = lizt.NewMapIterator(lizt.MapIteratorConfig{PointerIter: stream, Fn: strings.TrimSpace})
+					}
+
+					cfg := lizt.ShardingIteratorConfig{PointerIter: iter, Index: index, Count: count, Mode: mode}
+					shi, err := lizt.NewShardingIterator(cfg)
+					if err != nil {
+						t.Fatalf("NewShardingIterator() error = %v", err)
+					}
+					got := drainShard(t, shi)
+					_ = stream.Close()
+
+					cfg.PointerIter = lizt.NewSliceIterator("shards", lines, false)
+					reference, _ := lizt.NewShardingIterator(cfg)
+					want := drainShard(t, reference)
+
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("%s: shard %d of %d, mode %d: expected %v, got %v", name, index, count, mode, want, got)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package lizt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrNoIndex = errors.New("stream iterator has no index")
+
+const (
+	stateShuffleSeed  = "shuffle.seed"
+	stateShuffleCycle = "shuffle.cycle"
+)
+
+// ShufflingIterator is an iterator that walks a seeded pseudo-random permutation of the line indices of another
+// iterator. The permutation is computed on the fly, so the order is random but reproducible without loading the list.
+// The pointer is the position in the permutation, and the seed and cycle are saved by a PersistentIterator.
+type ShufflingIterator struct {
+	PointerIterator
+	perm       *permutation
+	pointer    uint64
+	seed       uint64
+	cycle      uint64
+	roundRobin bool
+	reshuffle  bool
+	mu         sync.Mutex
+}
+
+// ShufflingIteratorConfig is the config for a shuffling iterator. Reshuffle picks a new permutation on every
+// round-robin cycle.
+type ShufflingIteratorConfig struct {
+	PointerIter PointerIterator
+	Seed        int64
+	RoundRobin  bool
+	Reshuffle   bool
+}
+
+// NewShufflingIterator returns a new shuffling iterator. Every line is read with SetPointer, so a StreamIterator
+// has to be indexed (see NewIndexedStreamIterator) and uncompressed, since a compressed one would decompress from a
+// checkpoint for every line. Even then each line costs a Seek plus on average Stride()/2 discarded lines, so
+// shuffling a stream is far slower than reading it in order.
+func NewShufflingIterator(cfg ShufflingIteratorConfig) (*ShufflingIterator, error) {
+	if si, ok := cfg.PointerIter.(*StreamIterator); ok {
+		if !si.Indexed() {
+			return nil, fmt.Errorf("shuffle: name: %s -> %w", si.Name(), ErrNoIndex)
+		}
+		if !si.seekable() {
+			return nil, fmt.Errorf("shuffle: name: %s -> %w", si.Name(), ErrCompressed)
+		}
+	}
+
+	shi := &ShufflingIterator{
+		PointerIterator: cfg.PointerIter,
+		seed:            uint64(cfg.Seed),
+		roundRobin:      cfg.RoundRobin,
+		reshuffle:       cfg.Reshuffle,
+	}
+	shi.perm = newPermutation(uint64(cfg.PointerIter.Len()), shi.cycleSeed())
+	return shi, nil
+}
+
+// cycleSeed returns the permutation seed for the current cycle.
+func (shi *ShufflingIterator) cycleSeed() uint64 {
+	if !shi.reshuffle {
+		return shi.seed
+	}
+	return mix64(shi.seed + shi.cycle*0x9e3779b97f4a7c15)
+}
+
+// Next returns the next lines, of a given count, in shuffled order.
+func (shi *ShufflingIterator) Next(count int) ([]string, error) {
+	return shi.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, in shuffled order. It stops early if ctx is done.
+func (shi *ShufflingIterator) NextContext(ctx context.Context, count int) ([]string, error) {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	var lines []string
+	for i := 0; i < count; i++ {
+		if err := contextErr(ctx, shi.Name()); err != nil {
+			return lines, err
+		}
+
+		if shi.pointer >= shi.perm.n {
+			if !shi.roundRobin || shi.perm.n == 0 {
+				if len(lines) == 0 {
+					return nil, fmt.Errorf("shuffle: name: %s -> %w", shi.Name(), ErrNoMoreLines)
+				}
+				return lines, nil
+			}
+			shi.pointer = 0
+			shi.cycle++
+			shi.perm = newPermutation(shi.perm.n, shi.cycleSeed())
+		}
+
+		shi.PointerIterator.SetPointer(shi.perm.At(shi.pointer))
+		next, err := shi.PointerIterator.NextContext(ctx, 1)
+		if err != nil {
+			if ctx.Err() != nil {
+				return lines, contextErr(ctx, shi.Name())
+			}
+			return nil, fmt.Errorf("shuffle: name: %s -> %w", shi.Name(), err)
+		}
+		lines = append(lines, next[0])
+		shi.pointer++
+	}
+	return lines, nil
+}
+
+// MustNext returns the next lines, of a given count, from the iterator. Panics on error.
+func (shi *ShufflingIterator) MustNext(count int) []string {
+	lines, err := shi.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return lines
+}
+
+// NextOne returns the next line from the iterator.
+func (shi *ShufflingIterator) NextOne() (string, error) {
+	lines, err := shi.Next(1)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// MustNextOne returns the next line from the iterator. Panics on error.
+func (shi *ShufflingIterator) MustNextOne() string {
+	line, err := shi.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return line
+}
+
+// Pointer returns the position in the permutation.
+func (shi *ShufflingIterator) Pointer() uint64 {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	return shi.pointer
+}
+
+// SetPointer sets the position in the permutation.
+func (shi *ShufflingIterator) SetPointer(p uint64) {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	if p > shi.perm.n {
+		p = 0
+	}
+	shi.pointer = p
+}
+
+// Inc skips a position in the permutation.
+func (shi *ShufflingIterator) Inc() {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	shi.pointer++
+}
+
+// Seed returns the seed of the first cycle.
+func (shi *ShufflingIterator) Seed() int64 {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	return int64(shi.seed)
+}
+
+// State returns the seed and the round-robin cycle.
+func (shi *ShufflingIterator) State() map[string]uint64 {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	return map[string]uint64{
+		stateShuffleSeed:  shi.seed,
+		stateShuffleCycle: shi.cycle,
+	}
+}
+
+// SetState restores the seed and the round-robin cycle.
+func (shi *ShufflingIterator) SetState(state map[string]uint64) {
+	shi.mu.Lock()
+	defer shi.mu.Unlock()
+
+	if seed, ok := state[stateShuffleSeed]; ok {
+		shi.seed = seed
+	}
+	if cycle, ok := state[stateShuffleCycle]; ok {
+		shi.cycle = cycle
+	}
+	shi.perm = newPermutation(shi.perm.n, shi.cycleSeed())
+}
+
+// Unwrap returns the wrapped iterator.
+func (shi *ShufflingIterator) Unwrap() PointerIterator {
+	return shi.PointerIterator
+}
+
+// permutation is a seeded pseudo-random bijection on [0, n). It is a balanced Feistel network over the smallest
+// even power of two covering n, with cycle walking to stay inside the range, so it needs no table of n entries.
+type permutation struct {
+	n        uint64
+	halfBits uint
+	mask     uint64
+	keys     [6]uint64
+}
+
+func newPermutation(n, seed uint64) *permutation {
+	bits := uint(2)
+	for bits < 64 && uint64(1)<<bits < n {
+		bits += 2
+	}
+
+	p := &permutation{
+		n:        n,
+		halfBits: bits / 2,
+		mask:     uint64(1)<<(bits/2) - 1,
+	}
+	for i := range p.keys {
+		seed = mix64(seed + 0x9e3779b97f4a7c15)
+		p.keys[i] = seed
+	}
+	return p
+}
+
+// At returns the line index at position i of the permutation.
+func (p *permutation) At(i uint64) uint64 {
+	x := i
+	for {
+		x = p.encrypt(x)
+		if x < p.n {
+			return x
+		}
+	}
+}
+
+func (p *permutation) encrypt(x uint64) uint64 {
+	l, r := x>>p.halfBits, x&p.mask
+	for _, key := range p.keys {
+		l, r = r, l^(mix64(r^key)&p.mask)
+	}
+	return l<<p.halfBits | r
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,141 @@
+package lizt_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func hundredLines() []string {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = strconv.Itoa(i)
+	}
+	return lines
+}
+
+func TestShufflingIterator_Next_ShouldVisitEveryLineOnce(t *testing.T) {
+	lines := hundredLines()
+	shi, err := lizt.NewShufflingIterator(lizt.ShufflingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, lines, false),
+		Seed:        42,
+	})
+	if err != nil {
+		t.Fatalf("NewShufflingIterator() error = %v", err)
+	}
+
+	shuffled, err := shi.Next(len(lines))
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if reflect.DeepEqual(shuffled, lines) {
+		t.Errorf("expected %v to be shuffled", shuffled)
+	}
+
+	sorted := append([]string{}, shuffled...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, _ := strconv.Atoi(sorted[i])
+		b, _ := strconv.Atoi(sorted[j])
+		return a < b
+	})
+	if !reflect.DeepEqual(sorted, lines) {
+		t.Errorf("expected every line exactly once, got %v", shuffled)
+	}
+
+	_, err = shi.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestShufflingIterator_Next_ShouldBeReproducible(t *testing.T) {
+	next := func(seed int64) []string {
+		shi, err := lizt.NewShufflingIterator(lizt.ShufflingIteratorConfig{
+			PointerIter: lizt.NewSliceIterator(nameNumbers, hundredLines(), false),
+			Seed:        seed,
+		})
+		if err != nil {
+			t.Fatalf("NewShufflingIterator() error = %v", err)
+		}
+		return shi.MustNext(100)
+	}
+
+	if !reflect.DeepEqual(next(7), next(7)) {
+		t.Errorf("expected the same seed to give the same order")
+	}
+	if reflect.DeepEqual(next(7), next(8)) {
+		t.Errorf("expected different seeds to give different orders")
+	}
+}
+
+func TestShufflingIterator_Next_RoundRobin_Reshuffle(t *testing.T) {
+	shi, err := lizt.NewShufflingIterator(lizt.ShufflingIteratorConfig{
+		PointerIter: lizt.NewSliceIterator(nameNumbers, hundredLines(), false),
+		Seed:        1,
+		RoundRobin:  true,
+		Reshuffle:   true,
+	})
+	if err != nil {
+		t.Fatalf("NewShufflingIterator() error = %v", err)
+	}
+
+	first := shi.MustNext(100)
+	second := shi.MustNext(100)
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("expected the second cycle to be reshuffled")
+	}
+	if shi.State()["shuffle.cycle"] != 1 {
+		t.Errorf("expected cycle %d, got %d", 1, shi.State()["shuffle.cycle"])
+	}
+}
+
+func TestShufflingIterator_Persistent_ShouldResumeWithSavedSeed(t *testing.T) {
+	reference := lizt.B().SliceNamed(nameNumbers, hundredLines(), false).Shuffle(99).MustBuild().MustNext(10)
+
+	mem := NewInMemoryPersister()
+	first := lizt.B().SliceNamed(nameNumbers, hundredLines(), false).Shuffle(99).PersistTo(mem).MustBuild()
+	if !reflect.DeepEqual(first.MustNext(4), reference[:4]) {
+		t.Errorf("expected the persistent iterator to follow the seeded order")
+	}
+
+	if mem.pointers[nameNumbers] != 4 || mem.pointers[nameNumbers+".shuffle.seed"] != 99 {
+		t.Errorf("expected pointer and seed to be persisted, got %v", mem.pointers)
+	}
+
+	// a restart with a different seed continues the persisted order.
+	second := lizt.B().SliceNamed(nameNumbers, hundredLines(), false).Shuffle(12345).PersistTo(mem).MustBuild()
+	if !reflect.DeepEqual(second.MustNext(6), reference[4:]) {
+		t.Errorf("expected the restarted iterator to continue the persisted order")
+	}
+}
+
+func TestShufflingIterator_Stream(t *testing.T) {
+	fs, err := lizt.NewStreamIterator(copyToTemp(t, filenameTen), false)
+	if err != nil {
+		t.Fatalf("NewStreamIterator() error = %v", err)
+	}
+	_, err = lizt.NewShufflingIterator(lizt.ShufflingIteratorConfig{PointerIter: fs, Seed: 1})
+	if !errors.Is(err, lizt.ErrNoIndex) {
+		t.Errorf("wanted ErrNoIndex, got error = %v", err)
+	}
+
+	gz, err := lizt.NewIndexedStreamIterator(copyToTemp(t, "test/compressed/gzip.txt.gz"), false)
+	if err != nil {
+		t.Fatalf("NewIndexedStreamIterator() error = %v", err)
+	}
+	defer gz.Close()
+	_, err = lizt.NewShufflingIterator(lizt.ShufflingIteratorConfig{PointerIter: gz, Seed: 1})
+	if !errors.Is(err, lizt.ErrCompressed) {
+		t.Errorf("wanted ErrCompressed, got error = %v", err)
+	}
+
+	shuffled := lizt.B().StreamIndexed(copyToTemp(t, filenameTen)).Shuffle(3).MustBuild().MustNext(10)
+	sort.Strings(shuffled)
+	if !reflect.DeepEqual(shuffled, tenLines) {
+		t.Errorf("expected every line exactly once, got %v", shuffled)
+	}
+}
@@ -1,6 +1,7 @@
 package lizt
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -27,11 +28,20 @@ func NewSliceIterator(name string, lines []string, roundRobin bool) *SliceIterat
 
 // Next returns the next lines, of a given count, from the iterator.
 func (si *SliceIterator) Next(count int) ([]string, error) {
+	return si.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, from the iterator. It stops early if ctx is done.
+func (si *SliceIterator) NextContext(ctx context.Context, count int) ([]string, error) {
 	si.mu.Lock()
 	defer si.mu.Unlock()
 
 	var lines []string
 	for i := 0; i < count; i++ {
+		if err := contextErr(ctx, si.name); err != nil {
+			return lines, err
+		}
+
 		ptr := si.pointer.Load()
 		if ptr >= uint64(len(si.lines)) {
 			if si.roundRobin {
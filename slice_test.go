@@ -1,6 +1,7 @@
 package lizt_test
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"sort"
@@ -156,3 +157,22 @@ func TestSliceIterator_Next_Parallel(t *testing.T) {
 		t.Errorf("expected %v, got %v", letters, results)
 	}
 }
+
+func TestSliceIterator_NextContext_Canceled(t *testing.T) {
+	si := lizt.NewSliceIterator(nameNumbers, []string{"a", "b", "c"}, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := si.NextContext(ctx, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wanted context.Canceled, got error = %v", err)
+	}
+	if !strings.Contains(err.Error(), nameNumbers) {
+		t.Errorf("expected error to contain the iterator name, got %v", err)
+	}
+
+	if si.Pointer() != 0 {
+		t.Errorf("expected pointer to be %d, got %d", 0, si.Pointer())
+	}
+}
@@ -0,0 +1,107 @@
+package lizt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SortedFileBlacklist is a blacklist backend that binary searches a sorted, memory-mapped file instead of loading
+// it, so huge blacklists only cost the pages the lookups touch. The file must be sorted byte-wise with one entry
+// per line, e.g. with `LC_ALL=C sort -u` or SortBlacklistFile.
+type SortedFileBlacklist struct {
+	data     []byte
+	filename string
+	mu       sync.RWMutex
+}
+
+// NewSortedFileBlacklist maps a sorted blacklist file. Call Close to unmap it. Compressed files can't be mapped and
+// return ErrCompressed.
+func NewSortedFileBlacklist(filename string) (*SortedFileBlacklist, error) {
+	c, err := DetectCompression(filename)
+	if err != nil {
+		return nil, err
+	}
+	if c != CompressionNone {
+		return nil, fmt.Errorf("blacklist: %s: %s -> %w", filename, c, ErrCompressed)
+	}
+
+	file, err := OpenFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// the mapping stays valid after the file is closed.
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("file.Stat(): %s -> %w", filename, err)
+	}
+
+	data, err := mmapFile(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &SortedFileBlacklist{data: data, filename: filename}, nil
+}
+
+// Has returns true if the line is in the file.
+func (sb *SortedFileBlacklist) Has(line string) bool {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	target := []byte(line)
+	lo, hi := 0, len(sb.data)
+	for lo < hi {
+		// find the line around the midpoint. lo is always the start of a line.
+		start := lo + (hi-lo)/2
+		for start > lo && sb.data[start-1] != '\n' {
+			start--
+		}
+		end := start + bytes.IndexByte(sb.data[start:hi], '\n')
+		if end < start {
+			end = hi
+		}
+
+		switch bytes.Compare(bytes.TrimRight(sb.data[start:end], "\r"), target) {
+		case 0:
+			return true
+		case -1:
+			lo = end + 1
+		default:
+			hi = start
+		}
+	}
+	return false
+}
+
+// Close unmaps the file.
+func (sb *SortedFileBlacklist) Close() error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	err := munmapFile(sb.data)
+	sb.data = nil
+	return err
+}
+
+// SortBlacklistFile writes the lines of a blacklist file to dest sorted byte-wise and without duplicates, ready for
+// NewSortedFileBlacklist. The lines are sorted in memory.
+func SortBlacklistFile(source, dest string) error {
+	lines, err := ReadFromFile(source)
+	if err != nil {
+		return err
+	}
+	sort.Strings(lines)
+
+	sb := strings.Builder{}
+	for i, line := range lines {
+		if line == "" || (i > 0 && line == lines[i-1]) {
+			continue
+		}
+		sb.WriteString(line + "\n")
+	}
+	return WriteFileAtomic(dest, []byte(sb.String()))
+}
@@ -0,0 +1,81 @@
+package lizt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+func TestSortedFileBlacklist_Has(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := lizt.WriteToFile(append(hundredLines(), "7", "42", ""), source); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+	sorted := filepath.Join(t.TempDir(), "blacklist.sorted.txt")
+	if err := lizt.SortBlacklistFile(source, sorted); err != nil {
+		t.Fatalf("SortBlacklistFile() error = %v", err)
+	}
+
+	sb, err := lizt.NewSortedFileBlacklist(sorted)
+	if err != nil {
+		t.Fatalf("NewSortedFileBlacklist() error = %v", err)
+	}
+	defer sb.Close()
+
+	for _, line := range hundredLines() {
+		if !sb.Has(line) {
+			t.Errorf("expected %s to be blacklisted", line)
+		}
+	}
+	for _, line := range []string{"", "-1", "100", "007", "a", "99 "} {
+		if sb.Has(line) {
+			t.Errorf("expected %q not to be blacklisted", line)
+		}
+	}
+}
+
+func TestSortedFileBlacklist_Has_CRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(path, []byte("a\r\nb\r\nc"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	sb, err := lizt.NewSortedFileBlacklist(path)
+	if err != nil {
+		t.Fatalf("NewSortedFileBlacklist() error = %v", err)
+	}
+	defer sb.Close()
+
+	for _, line := range []string{"a", "b", "c"} {
+		if !sb.Has(line) {
+			t.Errorf("expected %s to be blacklisted", line)
+		}
+	}
+}
+
+func TestSortedFileBlacklist_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	sb, err := lizt.NewSortedFileBlacklist(path)
+	if err != nil {
+		t.Fatalf("NewSortedFileBlacklist() error = %v", err)
+	}
+	defer sb.Close()
+
+	if sb.Has("a") {
+		t.Errorf("expected an empty blacklist to have nothing")
+	}
+}
+
+func TestSortedFileBlacklist_Compressed(t *testing.T) {
+	_, err := lizt.NewSortedFileBlacklist("test/compressed/gzip.txt.gz")
+	if !errors.Is(err, lizt.ErrCompressed) {
+		t.Errorf("wanted ErrCompressed, got error = %v", err)
+	}
+}
@@ -2,15 +2,19 @@ package lizt
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"sync/atomic"
 )
 
-// StreamIterator is an iterator that reads from a file.
+// StreamIterator is an iterator that reads from a file. Compressed files are decompressed transparently.
 type StreamIterator struct {
 	reader     *bufio.Reader
+	file       io.ReadCloser
+	index      *LineIndex
 	pointer    *atomic.Uint64
 	filename   string
 	name       string
@@ -19,14 +23,39 @@ type StreamIterator struct {
 	mu         sync.RWMutex
 }
 
-// NewStreamIterator returns a new stream iterator.
+// NewStreamIterator returns a new stream iterator. If a valid line-offset index already exists next to the file it is
+// used for seeking, otherwise the file is read line by line.
 func NewStreamIterator(filename string, roundRobin bool) (*StreamIterator, error) {
-	count, err := FileLineCount(filename)
+	idx, err := LoadLineIndex(filename)
+	if err != nil {
+		idx = nil
+	}
+	return newStreamIterator(filename, roundRobin, idx)
+}
+
+// NewIndexedStreamIterator returns a new stream iterator backed by a line-offset index. The index is built and saved
+// next to the file if it doesn't exist or is stale, which makes SetPointer and round-robin restarts a single Seek.
+func NewIndexedStreamIterator(filename string, roundRobin bool) (*StreamIterator, error) {
+	idx, err := OpenLineIndex(filename)
 	if err != nil {
 		return nil, err
 	}
+	return newStreamIterator(filename, roundRobin, idx)
+}
+
+func newStreamIterator(filename string, roundRobin bool, idx *LineIndex) (*StreamIterator, error) {
+	var count int
+	if idx != nil {
+		count = idx.Lines()
+	} else {
+		var err error
+		count, err = FileLineCount(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	rdr, err := newFileReader(filename)
+	file, err := OpenReader(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +65,9 @@ func NewStreamIterator(filename string, roundRobin bool) (*StreamIterator, error
 	return &StreamIterator{
 		filename:   filename,
 		name:       name,
-		reader:     rdr,
+		file:       file,
+		reader:     bufio.NewReader(file),
+		index:      idx,
 		fileLines:  count,
 		pointer:    new(atomic.Uint64),
 		roundRobin: roundRobin,
@@ -45,21 +76,27 @@ func NewStreamIterator(filename string, roundRobin bool) (*StreamIterator, error
 
 // Next returns the next line from the iterator.
 func (si *StreamIterator) Next(count int) ([]string, error) {
+	return si.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next lines, of a given count, from the iterator. It stops early if ctx is done.
+func (si *StreamIterator) NextContext(ctx context.Context, count int) ([]string, error) {
 	si.mu.Lock()
 	defer si.mu.Unlock()
 
 	var lines []string
 	for i := 1; i <= count; i++ {
+		if err := contextErr(ctx, si.name); err != nil {
+			return lines, err
+		}
+
 		txt, err := si.reader.ReadString('\n')
 		if err != nil {
 			if si.roundRobin {
-				sr, err := newFileReader(si.filename)
-				if err != nil {
-					return nil, fmt.Errorf("newFileReader: %s -> %w", si.filename, err)
+				if err = si.seek(0); err != nil {
+					return nil, err
 				}
-				si.reader = sr
-
-				si.SetPointer(0)
+				si.pointer.Store(0)
 
 				txt, err = si.reader.ReadString('\n')
 				if err != nil {
@@ -123,20 +160,111 @@ func (si *StreamIterator) SetPointer(p uint64) {
 	si.pointer.Store(p)
 }
 
-// unsafePointerPairing create a new reader and iterate through the lines until it reaches the pointer.
+// unsafePointerPairing moves the reader to the pointer.
 func (si *StreamIterator) unsafePointerPairing(p uint64) {
-	sr, err := newFileReader(si.filename)
 	// even though this is unsafe, we'll just do nothing if there is an error.
+	_ = si.seek(p)
+}
+
+// seek moves the reader to the start of line p. With an index on an uncompressed file this is a single Seek
+// followed by at most Stride()-1 buffered line reads. Otherwise the file is reopened and read until it reaches p,
+// skipping straight to the nearest checkpoint when there is an index.
+func (si *StreamIterator) seek(p uint64) error {
+	var offset int64
+	skip := p
+	if si.index != nil {
+		offset, skip = si.index.Offset(p)
+		if seeker, ok := si.file.(io.Seeker); ok {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return fmt.Errorf("file.Seek(): %s -> %w", si.filename, err)
+			}
+			si.reader.Reset(si.file)
+			si.skipLines(skip)
+			return nil
+		}
+	}
+
+	file, err := OpenReader(si.filename)
 	if err != nil {
+		return err
+	}
+	_ = si.file.Close()
+	si.file = file
+	si.reader = bufio.NewReader(file)
+
+	if _, err = io.CopyN(io.Discard, si.reader, offset); err != nil {
+		return fmt.Errorf("io.CopyN(): %s -> %w", si.filename, err)
+	}
+	si.skipLines(skip)
+	return nil
+}
+
+// advancer is an iterator that can move forward to a line more cheaply than SetPointer. Wrappers whose pointer is
+// the pointer of the iterator they wrap pass advance on, so a stream is still read forward through them.
+type advancer interface {
+	advance(p uint64)
+}
+
+// advanceTo moves iter to line p, with advance if it's an advancer and SetPointer otherwise.
+func advanceTo(iter PointerIterator, p uint64) {
+	if a, ok := iter.(advancer); ok {
+		a.advance(p)
 		return
 	}
-	si.reader = sr
-	var i uint64
-	for i = 0; i < p; i++ {
+	iter.SetPointer(p)
+}
+
+// advance moves the reader forward to line p. It seeks only if the file is indexed and seekable and the checkpoint
+// before p lies past the current line; otherwise it reads on from where it is, so no line is read twice.
+func (si *StreamIterator) advance(p uint64) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	cur := si.pointer.Load()
+	if p < cur || p > uint64(si.Len()) {
+		si.SetPointer(p)
+		return
+	}
+
+	if _, seekable := si.file.(io.Seeker); seekable && si.index != nil {
+		if _, skip := si.index.Offset(p); p-skip > cur {
+			si.SetPointer(p)
+			return
+		}
+	}
+	si.skipLines(p - cur)
+	si.pointer.Store(p)
+}
+
+// skipLines reads and discards n lines.
+func (si *StreamIterator) skipLines(n uint64) {
+	for ; n > 0; n-- {
 		_, _ = si.reader.ReadString('\n')
 	}
 }
 
+// Indexed returns true if the iterator seeks using a line-offset index.
+func (si *StreamIterator) Indexed() bool {
+	return si.index != nil
+}
+
+// seekable returns true if the iterator has an index and can Seek to its checkpoints, i.e. the file isn't compressed.
+func (si *StreamIterator) seekable() bool {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	_, ok := si.file.(io.Seeker)
+	return ok && si.index != nil
+}
+
+// Close closes the underlying file.
+func (si *StreamIterator) Close() error {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	return si.file.Close()
+}
+
 // Inc increments the pointer.
 func (si *StreamIterator) Inc() {
 	si.pointer.Add(1)
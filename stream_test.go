@@ -1,6 +1,7 @@
 package lizt_test
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"sort"
@@ -179,3 +180,31 @@ func TestStreamIterator_Next_Parallel(t *testing.T) {
 		t.Errorf("expected %v, got %v", expected, results)
 	}
 }
+
+func TestStreamIterator_NextContext(t *testing.T) {
+	fs, err := lizt.NewStreamIterator(filenameTen, false)
+	if err != nil {
+		t.Errorf("NewStreamIterator() error = %v", err)
+	}
+
+	next, err := fs.NextContext(context.Background(), 3)
+	if err != nil {
+		t.Errorf("NextContext() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"a", "b", "c"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b", "c"}, next)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = fs.NextContext(ctx, 3)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wanted context.Canceled, got error = %v", err)
+	}
+
+	var expected uint64 = 3
+	if fs.Pointer() != expected {
+		t.Errorf("expected pointer to be %d, got %d", expected, fs.Pointer())
+	}
+}
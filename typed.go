@@ -0,0 +1,178 @@
+package lizt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSkipRecord is returned by a decoder for lines that aren't records, e.g. a CSV header or a comment. The line is
+// skipped without an error.
+var ErrSkipRecord = errors.New("skip record")
+
+// Decoder decodes a line into a record.
+type Decoder[T any] interface {
+	Decode(line string) (T, error)
+}
+
+// DecoderFunc is a function that implements Decoder.
+type DecoderFunc[T any] func(line string) (T, error)
+
+// Decode calls f(line).
+func (f DecoderFunc[T]) Decode(line string) (T, error) {
+	return f(line)
+}
+
+// DecodeError is returned when a line can't be decoded. Line is the 1-based line number in the underlying list,
+// or 0 if a wrapper that drops lines, e.g. a FilterIterator, makes it unknowable.
+type DecodeError struct {
+	Name string
+	Line uint64
+	Text string
+	Err  error
+}
+
+// Error implements error.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode: name: %s: line %d -> %v", e.Name, e.Line, e.Err)
+}
+
+// Unwrap returns the decoder error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedIterator is an iterator that decodes every line into a record of type T. It keeps the pointer semantics of
+// the wrapped iterator, so it can wrap a PersistentIterator.
+type TypedIterator[T any] struct {
+	iter    PointerIterator
+	decoder Decoder[T]
+}
+
+// NewTypedIterator returns a new typed iterator.
+func NewTypedIterator[T any](iter PointerIterator, decoder Decoder[T]) *TypedIterator[T] {
+	return &TypedIterator[T]{
+		iter:    iter,
+		decoder: decoder,
+	}
+}
+
+// Next returns the next records, of a given count, from the iterator.
+func (ti *TypedIterator[T]) Next(count int) ([]T, error) {
+	return ti.NextContext(context.Background(), count)
+}
+
+// NextContext returns the next records, of a given count, from the iterator. It stops early if ctx is done. Lines
+// that can't be decoded are skipped. The first one is reported as a *DecodeError, returned alongside the records
+// decoded from the same read, so no good line is lost.
+func (ti *TypedIterator[T]) NextContext(ctx context.Context, count int) ([]T, error) {
+	var records []T
+	skipped := 0
+	for len(records) < count {
+		// a round-robin list without a single record would be read forever.
+		if length := ti.iter.Len(); skipped >= length && length > 0 {
+			if len(records) > 0 {
+				return records, nil
+			}
+			return nil, fmt.Errorf("decode: name: %s -> %w", ti.iter.Name(), ErrNoMoreLines)
+		}
+
+		before := ti.iter.Pointer()
+		lines, err := ti.iter.NextContext(ctx, count-len(records))
+		exact := ti.exact(before, len(lines))
+
+		var decodeErr *DecodeError
+		for i, line := range lines {
+			record, decErr := ti.decoder.Decode(line)
+			if errors.Is(decErr, ErrSkipRecord) {
+				skipped++
+				continue
+			}
+			if decErr != nil {
+				if decodeErr == nil {
+					decodeErr = &DecodeError{Name: ti.iter.Name(), Text: line, Err: decErr}
+					if exact {
+						decodeErr.Line = (before+uint64(i))%uint64(ti.iter.Len()) + 1
+					}
+				}
+				continue
+			}
+			skipped = 0
+			records = append(records, record)
+		}
+
+		if decodeErr != nil {
+			return records, decodeErr
+		}
+		if err != nil {
+			if len(records) == 0 || ctx.Err() != nil {
+				return records, err
+			}
+			return records, nil
+		}
+	}
+	return records, nil
+}
+
+// exact returns true if reading n lines moved the pointer by exactly n lines, wrapping around at the end of a
+// round-robin list, so every line's position is known.
+func (ti *TypedIterator[T]) exact(before uint64, n int) bool {
+	length := uint64(ti.iter.Len())
+	if n == 0 || length == 0 {
+		return false
+	}
+	return ti.iter.Pointer() == (before+uint64(n)-1)%length+1
+}
+
+// MustNext returns the next records, of a given count, from the iterator. Panics on error.
+func (ti *TypedIterator[T]) MustNext(count int) []T {
+	records, err := ti.Next(count)
+	if err != nil {
+		panic(err)
+	}
+	return records
+}
+
+// NextOne returns the next record from the iterator.
+func (ti *TypedIterator[T]) NextOne() (T, error) {
+	records, err := ti.Next(1)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return records[0], nil
+}
+
+// MustNextOne returns the next record from the iterator. Panics on error.
+func (ti *TypedIterator[T]) MustNextOne() T {
+	record, err := ti.NextOne()
+	if err != nil {
+		panic(err)
+	}
+	return record
+}
+
+// Name returns the name of the wrapped iterator.
+func (ti *TypedIterator[T]) Name() string {
+	return ti.iter.Name()
+}
+
+// Len returns the number of lines of the wrapped iterator.
+func (ti *TypedIterator[T]) Len() int {
+	return ti.iter.Len()
+}
+
+// Pointer returns the pointer of the wrapped iterator.
+func (ti *TypedIterator[T]) Pointer() uint64 {
+	return ti.iter.Pointer()
+}
+
+// SetPointer sets the pointer of the wrapped iterator.
+func (ti *TypedIterator[T]) SetPointer(p uint64) {
+	ti.iter.SetPointer(p)
+}
+
+// Unwrap returns the wrapped iterator.
+func (ti *TypedIterator[T]) Unwrap() PointerIterator {
+	return ti.iter
+}
@@ -0,0 +1,93 @@
+package lizt_test
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"git.faze.center/netr/lizt"
+)
+
+var intDecoder = lizt.DecoderFunc[int](func(line string) (int, error) {
+	if strings.HasPrefix(line, "#") {
+		return 0, lizt.ErrSkipRecord
+	}
+	return strconv.Atoi(line)
+})
+
+func TestTypedIterator_Next(t *testing.T) {
+	ti := lizt.NewTypedIterator[int](lizt.NewSliceIterator(nameNumbers, []string{"# numbers", "1", "2", "3"}, false), intDecoder)
+
+	next, err := ti.Next(2)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []int{1, 2}) {
+		t.Errorf("expected %v, got %v", []int{1, 2}, next)
+	}
+
+	next, err = ti.Next(5)
+	if err != nil {
+		t.Errorf("Next() error = %v", err)
+	}
+	if !reflect.DeepEqual(next, []int{3}) {
+		t.Errorf("expected %v, got %v", []int{3}, next)
+	}
+
+	_, err = ti.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestTypedIterator_Next_DecodeError(t *testing.T) {
+	ti := lizt.NewTypedIterator[int](lizt.NewSliceIterator(nameNumbers, []string{"1", "two", "3", "4"}, true), intDecoder)
+
+	next, err := ti.Next(3)
+	var decodeErr *lizt.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("wanted a DecodeError, got error = %v", err)
+	}
+	if decodeErr.Line != 2 || decodeErr.Text != "two" {
+		t.Errorf("expected line %d (%s), got line %d (%s)", 2, "two", decodeErr.Line, decodeErr.Text)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected the decoder error to be wrapped, got %v", err)
+	}
+	if !reflect.DeepEqual(next, []int{1, 3}) {
+		t.Errorf("expected the good lines %v, got %v", []int{1, 3}, next)
+	}
+
+	// the line number follows a round-robin list around.
+	next, err = ti.Next(3)
+	if !errors.As(err, &decodeErr) || decodeErr.Line != 2 {
+		t.Errorf("wanted a DecodeError on line %d, got error = %v", 2, err)
+	}
+	if !reflect.DeepEqual(next, []int{4, 1}) {
+		t.Errorf("expected %v, got %v", []int{4, 1}, next)
+	}
+}
+
+func TestTypedIterator_Next_OnlySkippedLines(t *testing.T) {
+	ti := lizt.NewTypedIterator[int](lizt.NewSliceIterator(nameNumbers, []string{"# a", "# b"}, true), intDecoder)
+
+	_, err := ti.Next(1)
+	if !errors.Is(err, lizt.ErrNoMoreLines) {
+		t.Errorf("wanted ErrNoMoreLines, got error = %v", err)
+	}
+}
+
+func TestTypedIterator_Persistent(t *testing.T) {
+	mem := NewInMemoryPersister()
+	p := lizt.B().SliceNamed(nameNumbers, []string{"1", "2", "3"}, false).PersistTo(mem).MustBuild()
+
+	ti := lizt.NewTypedIterator[int](p, intDecoder)
+	if next := ti.MustNext(2); !reflect.DeepEqual(next, []int{1, 2}) {
+		t.Errorf("expected %v, got %v", []int{1, 2}, next)
+	}
+	if mem.pointers[nameNumbers] != 2 {
+		t.Errorf("expected %d, got %d", 2, mem.pointers[nameNumbers])
+	}
+}